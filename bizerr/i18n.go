@@ -5,28 +5,57 @@ import (
 	"errors"
 	"github.com/cloudwego/hertz/pkg/common/hlog"
 	"github.com/hertz-contrib/i18n"
+	goi18n "github.com/nicksnyder/go-i18n/v2/i18n"
 	"strings"
 )
 
 type bizError struct {
-	c int
-	s string
+	c    int
+	s    string
+	data map[string]any
 }
 
 // New returns an error that formats as the given text.
 func New(code int, key string) error {
-	return &bizError{code, key}
+	return &bizError{c: code, s: key}
+}
+
+// WithData returns a copy of the biz error carrying the i18n template data,
+// errors.Is still matches the original error
+func WithData(err error, data map[string]any) error {
+	var e *bizError
+	if !errors.As(err, &e) {
+		return err
+	}
+	return &bizError{c: e.c, s: e.s, data: data}
 }
 
 func (e *bizError) Error() string {
 	return e.s
 }
 
+// Is 错误码和消息相同即为同一错误
+func (e *bizError) Is(target error) bool {
+	t, ok := target.(*bizError)
+	return ok && t.c == e.c && t.s == e.s
+}
+
+// 获取国际化消息，存在模板数据时渲染模板
+func (e *bizError) message(ctx context.Context) (string, error) {
+	if e.data == nil {
+		return i18n.GetMessage(ctx, e.s)
+	}
+	return i18n.GetMessage(ctx, &goi18n.LocalizeConfig{
+		MessageID:    e.s,
+		TemplateData: e.data,
+	})
+}
+
 // WrapBizError 错误信息转换
 func WrapBizError(ctx context.Context, err error) error {
-	e, ok := err.(*bizError)
-	if ok && strings.Contains(e.s, ".") {
-		msg, er := i18n.GetMessage(ctx, e.s)
+	var e *bizError
+	if errors.As(err, &e) && strings.Contains(e.s, ".") {
+		msg, er := e.message(ctx)
 		if er != nil {
 			hlog.Warn("i18n: get message error: %v", er)
 			return err
@@ -38,10 +67,10 @@ func WrapBizError(ctx context.Context, err error) error {
 
 // BizErrorMsg 获取错误信息
 func BizErrorMsg(ctx context.Context, err error) (int, string) {
-	e, ok := err.(*bizError)
-	if ok {
+	var e *bizError
+	if errors.As(err, &e) {
 		if strings.Contains(e.s, ".") {
-			msg, er := i18n.GetMessage(ctx, e.s)
+			msg, er := e.message(ctx)
 			if er != nil {
 				hlog.Warn("i18n: get message error: %v", er)
 				return e.c, err.Error()
@@ -21,20 +21,19 @@ func main() {
 		DB:       0,
 	}))
 
+	router := keyauth.NewRouter()
+	router.Match("/**").Check(keyauth.RequireLogin())
+	router.Match("/login").Ignore()
+
 	h.Use(keyauth.New(
-		keyauth.WithFilter(func(ctx context.Context, c *app.RequestContext) bool {
-			if string(c.Request.RequestURI()) == "/login" {
-				return true
-			}
-			return false
-		}),
+		keyauth.WithRouter(router),
 		keyauth.WithManager(mgr),
 	),
 	)
 
 	// 无需验证
 	h.GET("/login", func(ctx context.Context, c *app.RequestContext) {
-		token, err := mgr.Login(ctx, "123", satoken.LoginModel{})
+		token, err := keyauth.Login(ctx, c, mgr, "123", satoken.LoginModel{})
 		if err != nil {
 			c.WriteString("error:" + err.Error())
 			return
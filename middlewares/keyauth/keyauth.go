@@ -7,9 +7,14 @@ import (
 	"github.com/cloudwego/hertz/pkg/common/utils"
 	"github.com/myhaiting/go-fly-lib/satoken"
 	"net/http"
+	"sync"
+	"time"
 )
 
-const hertzAuthKey = "hertzAuth"
+const (
+	hertzAuthKey      = "hertzAuth"
+	hertzAuthTypesKey = "hertzAuthTypes"
+)
 
 type ctxStore struct {
 	Instance   *satoken.Manager // Token Manager
@@ -17,6 +22,14 @@ type ctxStore struct {
 	LoginId    string           // LoginId
 }
 
+// 按登录类型缓存的登录信息，只在请求处理中使用，处理函数可能在多个协程中获取
+type ctxTypeStores struct {
+	mu         sync.Mutex
+	tokenValue string
+	mgrs       []*satoken.Manager
+	stores     map[string]*ctxStore
+}
+
 func New(opts ...Option) app.HandlerFunc {
 	cfg := NewOptions(opts...)
 	// token look up
@@ -29,24 +42,79 @@ func New(opts ...Option) app.HandlerFunc {
 			ctx.Next(c)
 			return
 		}
+		// Route rules
+		var rule *Rule
+		var vars map[string]string
+		if cfg.router != nil {
+			// 未匹配任何规则的请求仍需认证，只有 Ignore 规则跳过
+			rule, vars = cfg.router.match(string(ctx.Method()), string(ctx.Path()))
+			if rule != nil && rule.ignore {
+				ctx.Next(c)
+				return
+			}
+		}
 		// Extract and verify key
 		tokenValue, err := extractor(ctx)
 		if err != nil {
 			cfg.errorHandler(c, ctx, err)
 			return
 		}
-		// Get login info
-		loginId, err := cfg.mgr.GetLoginId(c, tokenValue)
+		// 按路由规则选择Manager
+		loginType := ""
+		if rule != nil {
+			loginType = rule.loginType
+		}
+		mgrs, err := cfg.getManagers(loginType)
 		if err != nil {
 			cfg.errorHandler(c, ctx, err)
 			return
 		}
-		store := &ctxStore{
-			Instance:   cfg.mgr,
-			TokenValue: tokenValue,
-			LoginId:    loginId,
+		// Get login info, 依次尝试每个Manager, 首个认证成功即停止
+		var store *ctxStore
+		var firstErr error
+		for _, mgr := range mgrs {
+			loginId, err := mgr.GetLoginId(c, tokenValue)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			store = &ctxStore{
+				Instance:   mgr,
+				TokenValue: tokenValue,
+				LoginId:    loginId,
+			}
+			break
+		}
+		if store == nil {
+			cfg.errorHandler(c, ctx, firstErr)
+			return
+		}
+		// 其他登录类型在按类型获取时才认证
+		stores := &ctxTypeStores{
+			tokenValue: tokenValue,
+			mgrs:       mgrs,
+			stores:     map[string]*ctxStore{store.Instance.GetLoginType(): store},
+		}
+		// 二级认证
+		if len(cfg.safeServices) > 0 {
+			if err = store.Instance.CheckSafe(c, tokenValue, cfg.safeServices...); err != nil {
+				cfg.errorHandler(c, ctx, err)
+				return
+			}
 		}
 		withValueCtx := context.WithValue(c, hertzAuthKey, store)
+		withValueCtx = context.WithValue(withValueCtx, hertzAuthTypesKey, stores)
+		// 路由规则校验
+		if rule != nil {
+			for _, check := range rule.checks {
+				if err = check(withValueCtx, ctx, vars); err != nil {
+					cfg.errorHandler(withValueCtx, ctx, err)
+					return
+				}
+			}
+		}
 		// 参数验证
 		if cfg.verifyHandler != nil {
 			if err = cfg.verifyHandler(withValueCtx, ctx); err != nil {
@@ -73,6 +141,57 @@ func ctxGet(ctx context.Context) (*ctxStore, error) {
 	return h, nil
 }
 
+// 获取指定登录类型的登录信息，首次获取时才用该类型的Manager认证
+func ctxGetByType(ctx context.Context, loginType string) (*ctxStore, error) {
+	stores, ok := ctx.Value(hertzAuthTypesKey).(*ctxTypeStores)
+	if !ok {
+		return nil, fmt.Errorf("auth error: %v", "Config is nil")
+	}
+	stores.mu.Lock()
+	defer stores.mu.Unlock()
+	if store, ok := stores.stores[loginType]; ok {
+		return store, nil
+	}
+	for _, mgr := range stores.mgrs {
+		if mgr.GetLoginType() != loginType {
+			continue
+		}
+		loginId, err := mgr.GetLoginId(ctx, stores.tokenValue)
+		if err != nil {
+			return nil, err
+		}
+		store := &ctxStore{
+			Instance:   mgr,
+			TokenValue: stores.tokenValue,
+			LoginId:    loginId,
+		}
+		stores.stores[loginType] = store
+		return store, nil
+	}
+	return nil, fmt.Errorf("auth error: login type %s not logged in", loginType)
+}
+
+// 使用请求的客户端信息补全登录参数
+func withClientInfo(ctx *app.RequestContext, model satoken.LoginModel) satoken.LoginModel {
+	if model.Ip == "" {
+		model.Ip = ctx.ClientIP()
+	}
+	if model.UserAgent == "" {
+		model.UserAgent = string(ctx.UserAgent())
+	}
+	return model
+}
+
+// Login login with the client ip and user agent of the request recorded on the token
+func Login(c context.Context, ctx *app.RequestContext, mgr *satoken.Manager, loginId any, model satoken.LoginModel) (string, error) {
+	return mgr.Login(c, loginId, withClientInfo(ctx, model))
+}
+
+// LoginWithRefresh login with a refresh token, the client ip and user agent of the request are recorded on the token
+func LoginWithRefresh(c context.Context, ctx *app.RequestContext, mgr *satoken.Manager, loginId any, model satoken.LoginModel) (*satoken.TokenPair, error) {
+	return mgr.LoginWithRefresh(c, loginId, withClientInfo(ctx, model))
+}
+
 // Logout logout 当前账户
 func Logout(ctx context.Context) error {
 	store, err := ctxGet(ctx)
@@ -91,6 +210,69 @@ func LogoutByLoginId(ctx context.Context, loginId any, device string) error {
 	return store.Instance.LogoutByLoginId(ctx, loginId, device)
 }
 
+// KickoutByLoginId 指定用户踢下线
+func KickoutByLoginId(ctx context.Context, loginId any, device string) error {
+	store, err := ctxGet(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Instance.KickoutByLoginId(ctx, loginId, device)
+}
+
+// OpenSafe 当前Token开启二级认证
+func OpenSafe(ctx context.Context, service string, duration time.Duration) error {
+	store, err := ctxGet(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Instance.OpenSafe(ctx, store.TokenValue, service, duration)
+}
+
+// CloseSafe 当前Token关闭二级认证
+func CloseSafe(ctx context.Context, services ...string) error {
+	store, err := ctxGet(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Instance.CloseSafe(ctx, store.TokenValue, services...)
+}
+
+// CheckPermissionAnd 当前账户拥有全部权限
+func CheckPermissionAnd(ctx context.Context, permissions ...string) error {
+	store, err := ctxGet(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Instance.CheckPermissionAnd(ctx, store.LoginId, permissions...)
+}
+
+// CheckPermissionOr 当前账户拥有任一权限
+func CheckPermissionOr(ctx context.Context, permissions ...string) error {
+	store, err := ctxGet(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Instance.CheckPermissionOr(ctx, store.LoginId, permissions...)
+}
+
+// CheckRoleAnd 当前账户拥有全部角色
+func CheckRoleAnd(ctx context.Context, roles ...string) error {
+	store, err := ctxGet(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Instance.CheckRoleAnd(ctx, store.LoginId, roles...)
+}
+
+// CheckRoleOr 当前账户拥有任一角色
+func CheckRoleOr(ctx context.Context, roles ...string) error {
+	store, err := ctxGet(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Instance.CheckRoleOr(ctx, store.LoginId, roles...)
+}
+
 // GetLoginId get login id
 func GetLoginId(ctx context.Context) (string, error) {
 	store, err := ctxGet(ctx)
@@ -100,6 +282,24 @@ func GetLoginId(ctx context.Context) (string, error) {
 	return store.LoginId, nil
 }
 
+// GetLoginIdByType get login id of the login type
+func GetLoginIdByType(ctx context.Context, loginType string) (string, error) {
+	store, err := ctxGetByType(ctx, loginType)
+	if err != nil {
+		return "", err
+	}
+	return store.LoginId, nil
+}
+
+// GetSessionByType get token session of the login type
+func GetSessionByType(ctx context.Context, loginType string) (*satoken.Session, error) {
+	store, err := ctxGetByType(ctx, loginType)
+	if err != nil {
+		return nil, err
+	}
+	return store.Instance.GetSession(ctx, store.TokenValue, true)
+}
+
 // GetSession get token session
 func GetSession(ctx context.Context) (*satoken.Session, error) {
 	store, err := ctxGet(ctx)
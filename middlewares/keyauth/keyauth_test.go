@@ -0,0 +1,91 @@
+package keyauth
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/myhaiting/go-fly-lib/satoken/store"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func newTestContext(method, path, token string) *app.RequestContext {
+	ctx := app.NewContext(0)
+	ctx.Request.Header.SetMethod(method)
+	ctx.Request.SetRequestURI(path)
+	if token != "" {
+		ctx.Request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return ctx
+}
+
+func TestNew_Router(t *testing.T) {
+	mgr := satoken.NewDefaultManager()
+	s := store.NewMemoryStore()
+	defer s.Close()
+	mgr.MapTokenStorage(s)
+	token, _ := mgr.Login(context.Background(), 10001, satoken.LoginModel{})
+
+	r := NewRouter()
+	r.Match("/public/**").Ignore()
+	r.Match("/admin/**").Check(RequireRole("admin"))
+	handler := New(WithManager(mgr), WithRouter(r))
+
+	// 未匹配任何规则的请求仍需认证
+	ctx := newTestContext("GET", "/user/info", "")
+	handler(context.Background(), ctx)
+	assert.True(t, ctx.IsAborted())
+
+	ctx = newTestContext("GET", "/user/info", token)
+	handler(context.Background(), ctx)
+	assert.Equal(t, http.StatusOK, ctx.Response.StatusCode())
+	assert.False(t, ctx.IsAborted())
+
+	ctx = newTestContext("GET", "/public/home", "")
+	handler(context.Background(), ctx)
+	assert.False(t, ctx.IsAborted())
+}
+
+func TestNew_Managers(t *testing.T) {
+	s := store.NewMemoryStore()
+	defer s.Close()
+	userMgr := satoken.NewManager("user")
+	userMgr.MapTokenStorage(s)
+	adminMgr := satoken.NewManager("admin")
+	adminMgr.MapTokenStorage(s)
+	token, _ := userMgr.Login(context.Background(), 10001, satoken.LoginModel{Token: "shared-token"})
+	_, _ = adminMgr.Login(context.Background(), 20001, satoken.LoginModel{Token: token})
+
+	var next context.Context
+	ctx := newTestContext("GET", "/user/info", token)
+	ctx.SetHandlers(app.HandlersChain{
+		New(WithManagers(userMgr, adminMgr)),
+		func(c context.Context, ctx *app.RequestContext) { next = c },
+	})
+	ctx.Next(context.Background())
+	assert.False(t, ctx.IsAborted())
+
+	// 首个Manager认证成功即停止，其他类型按需认证
+	stores := next.Value(hertzAuthTypesKey).(*ctxTypeStores)
+	assert.Len(t, stores.stores, 1)
+	loginId, err := GetLoginId(next)
+	assert.Nil(t, err)
+	assert.Equal(t, "10001", loginId)
+	// 处理函数可在多个协程中按类型获取
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loginId, err := GetLoginIdByType(next, "admin")
+			assert.Nil(t, err)
+			assert.Equal(t, "20001", loginId)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, stores.stores, 2)
+	_, err = GetLoginIdByType(next, "guest")
+	assert.NotNil(t, err)
+}
@@ -3,7 +3,9 @@ package keyauth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/cloudwego/hertz/pkg/common/utils"
+	"github.com/myhaiting/go-fly-lib/bizerr"
 	"github.com/myhaiting/go-fly-lib/satoken"
 	"net/http"
 
@@ -54,6 +56,18 @@ type Options struct {
 
 	// Manager
 	mgr *satoken.Manager
+
+	// mgrs are tried in order, the first manager accepting the token is the current login.
+	// Optional. Default: mgr
+	mgrs []*satoken.Manager
+
+	// safeServices requires the token to be in the second-level authentication window of these services.
+	// Optional. Default: nil
+	safeServices []string
+
+	// router decides by route rules which requests are authenticated and which checks they need.
+	// Optional. Default: nil, all requests are authenticated without checks
+	router *Router
 }
 
 func (o *Options) Apply(opts []Option) {
@@ -73,6 +87,15 @@ func NewOptions(opts ...Option) *Options {
 				})
 				return
 			}
+			// 没有权限报403
+			if errors.Is(err, satoken.ErrNoPermission) || errors.Is(err, satoken.ErrNoRole) || errors.Is(err, satoken.ErrNotSafe) {
+				_, msg := bizerr.BizErrorMsg(c, err)
+				ctx.AbortWithStatusJSON(http.StatusForbidden, utils.H{
+					"code": 1,
+					"msg":  msg,
+				})
+				return
+			}
 			// Token不可用
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, utils.H{
 				"code": 1,
@@ -83,12 +106,32 @@ func NewOptions(opts ...Option) *Options {
 		keyLookup:  "header:" + consts.HeaderAuthorization,
 	}
 	options.Apply(opts)
-	if options.mgr == nil {
+	if options.mgr != nil {
+		options.mgrs = append([]*satoken.Manager{options.mgr}, options.mgrs...)
+	}
+	if len(options.mgrs) == 0 {
 		panic("satoken manager not found")
 	}
+	options.mgr = options.mgrs[0]
 	return options
 }
 
+// getManagers 获取登录类型对应的Manager，loginType 为空时返回全部
+func (o *Options) getManagers(loginType string) ([]*satoken.Manager, error) {
+	if loginType == "" {
+		return o.mgrs, nil
+	}
+	for _, mgr := range o.mgrs {
+		if mgr.GetLoginType() == loginType {
+			return []*satoken.Manager{mgr}, nil
+		}
+	}
+	if mgr := satoken.Get(loginType); mgr != nil {
+		return []*satoken.Manager{mgr}, nil
+	}
+	return nil, fmt.Errorf("satoken manager of login type %s not found", loginType)
+}
+
 func WithFilter(f KeyAuthFilterHandler) Option {
 	return Option{
 		F: func(o *Options) {
@@ -121,9 +164,51 @@ func WithManager(f *satoken.Manager) Option {
 	}
 }
 
+// WithManagers try the managers in order, use it for several login types sharing one server
+func WithManagers(mgrs ...*satoken.Manager) Option {
+	return Option{
+		F: func(o *Options) {
+			o.mgrs = append(o.mgrs, mgrs...)
+		},
+	}
+}
+
+// WithLoginTypes try the registered managers of the login types in order, see satoken.Register
+func WithLoginTypes(loginTypes ...string) Option {
+	return Option{
+		F: func(o *Options) {
+			for _, loginType := range loginTypes {
+				mgr := satoken.Get(loginType)
+				if mgr == nil {
+					panic(fmt.Sprintf("satoken manager of login type %s not found", loginType))
+				}
+				o.mgrs = append(o.mgrs, mgr)
+			}
+		},
+	}
+}
+
 func WithKeyLookUp(lookup, authScheme string) Option {
 	return Option{func(o *Options) {
 		o.keyLookup = lookup
 		o.authScheme = authScheme
 	}}
 }
+
+// WithSafe requires an open second-level authentication window of the services, see satoken.Manager.OpenSafe
+func WithSafe(services ...string) Option {
+	return Option{func(o *Options) {
+		if len(services) == 0 {
+			services = []string{satoken.DefaultSafeService}
+		}
+		o.safeServices = services
+	}}
+}
+
+// WithRouter authenticate requests by route rules, requests matching no rule are still authenticated,
+// only the Ignore rules skip the authentication
+func WithRouter(r *Router) Option {
+	return Option{func(o *Options) {
+		o.router = r
+	}}
+}
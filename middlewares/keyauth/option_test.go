@@ -0,0 +1,32 @@
+package keyauth
+
+import (
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOptions_GetManagers(t *testing.T) {
+	user := satoken.NewManager("user")
+	admin := satoken.NewManager("admin")
+	satoken.Register("admin", admin)
+
+	cfg := NewOptions(WithManager(user), WithLoginTypes("admin"))
+	mgrs, err := cfg.getManagers("")
+	assert.NoError(t, err)
+	assert.Equal(t, []*satoken.Manager{user, admin}, mgrs)
+
+	mgrs, err = cfg.getManagers("admin")
+	assert.NoError(t, err)
+	assert.Equal(t, []*satoken.Manager{admin}, mgrs)
+
+	_, err = cfg.getManagers("guest")
+	assert.Error(t, err)
+
+	assert.Panics(t, func() {
+		NewOptions()
+	})
+	assert.Panics(t, func() {
+		NewOptions(WithLoginTypes("guest"))
+	})
+}
@@ -0,0 +1,178 @@
+package keyauth
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/app"
+	"github.com/myhaiting/go-fly-lib/antpath"
+	"strings"
+)
+
+// RouteCheck is executed after authentication when its rule wins, vars holds the extracted {var} values
+type RouteCheck func(c context.Context, ctx *app.RequestContext, vars map[string]string) error
+
+// Router ordered route rules, the most specific matching rule wins,
+// rules with equally specific patterns are resolved by registration order
+type Router struct {
+	matcher *antpath.AntPathMatcher
+	rules   []*Rule
+}
+
+// Rule authorization rule of the matched routes
+type Rule struct {
+	patterns  []string
+	excludes  []string
+	methods   []string
+	checks    []RouteCheck
+	ignore    bool
+	loginType string
+}
+
+// NewRouter create an empty route rule set
+func NewRouter() *Router {
+	return &Router{
+		matcher: antpath.New(),
+	}
+}
+
+// Match add a rule matching the ant-style path patterns
+func (r *Router) Match(patterns ...string) *Rule {
+	rule := &Rule{patterns: patterns}
+	r.rules = append(r.rules, rule)
+	return rule
+}
+
+// NotMatch exclude the paths matching the patterns from the rule
+func (r *Rule) NotMatch(patterns ...string) *Rule {
+	r.excludes = append(r.excludes, patterns...)
+	return r
+}
+
+// Method limit the rule to the http methods
+func (r *Rule) Method(methods ...string) *Rule {
+	for _, method := range methods {
+		r.methods = append(r.methods, strings.ToUpper(method))
+	}
+	return r
+}
+
+// Check add the checks executed in order when the rule wins
+func (r *Rule) Check(checks ...RouteCheck) *Rule {
+	r.checks = append(r.checks, checks...)
+	return r
+}
+
+// LoginType authenticate the matched routes only with the manager of the login type
+func (r *Rule) LoginType(loginType string) *Rule {
+	r.loginType = loginType
+	return r
+}
+
+// Ignore skip authentication for the matched routes
+func (r *Rule) Ignore() *Rule {
+	r.ignore = true
+	return r
+}
+
+func (r *Rule) allowMethod(method string) bool {
+	if len(r.methods) == 0 {
+		return true
+	}
+	for _, item := range r.methods {
+		if item == method {
+			return true
+		}
+	}
+	return false
+}
+
+// 返回规则中与 path 匹配的最具体的 pattern
+func (r *Router) bestPattern(rule *Rule, comparator *antpath.AntPatternComparator, path string) (string, bool) {
+	best, found := "", false
+	for _, pattern := range rule.patterns {
+		if !r.matcher.Match(pattern, path) {
+			continue
+		}
+		if !found || comparator.Compare(pattern, best) < 0 {
+			best, found = pattern, true
+		}
+	}
+	return best, found
+}
+
+// match find the winning rule of the request and extract its uri template variables
+func (r *Router) match(method, path string) (*Rule, map[string]string) {
+	comparator := r.matcher.GetPatternComparator(path)
+	var winner *Rule
+	var winnerPattern string
+	for _, rule := range r.rules {
+		if !rule.allowMethod(method) {
+			continue
+		}
+		pattern, ok := r.bestPattern(rule, comparator, path)
+		if !ok || r.excluded(rule, path) {
+			continue
+		}
+		if winner == nil || comparator.Compare(pattern, winnerPattern) < 0 {
+			winner, winnerPattern = rule, pattern
+		}
+	}
+	if winner == nil {
+		return nil, nil
+	}
+	return winner, *r.matcher.ExtractUriTemplateVariables(winnerPattern, path)
+}
+
+func (r *Router) excluded(rule *Rule, path string) bool {
+	for _, pattern := range rule.excludes {
+		if r.matcher.Match(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireLogin only requires a valid token
+func RequireLogin() RouteCheck {
+	return func(c context.Context, ctx *app.RequestContext, vars map[string]string) error {
+		return nil
+	}
+}
+
+// RequireRole requires all the roles
+func RequireRole(roles ...string) RouteCheck {
+	return func(c context.Context, ctx *app.RequestContext, vars map[string]string) error {
+		return CheckRoleAnd(c, roles...)
+	}
+}
+
+// RequireAnyRole requires any of the roles
+func RequireAnyRole(roles ...string) RouteCheck {
+	return func(c context.Context, ctx *app.RequestContext, vars map[string]string) error {
+		return CheckRoleOr(c, roles...)
+	}
+}
+
+// RequirePermission requires all the permissions
+func RequirePermission(permissions ...string) RouteCheck {
+	return func(c context.Context, ctx *app.RequestContext, vars map[string]string) error {
+		return CheckPermissionAnd(c, permissions...)
+	}
+}
+
+// RequireAnyPermission requires any of the permissions
+func RequireAnyPermission(permissions ...string) RouteCheck {
+	return func(c context.Context, ctx *app.RequestContext, vars map[string]string) error {
+		return CheckPermissionOr(c, permissions...)
+	}
+}
+
+// RequireSafe requires an open second-level authentication window of the services
+func RequireSafe(services ...string) RouteCheck {
+	return func(c context.Context, ctx *app.RequestContext, vars map[string]string) error {
+		store, err := ctxGet(c)
+		if err != nil {
+			return err
+		}
+		return store.Instance.CheckSafe(c, store.TokenValue, services...)
+	}
+}
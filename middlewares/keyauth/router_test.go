@@ -0,0 +1,40 @@
+package keyauth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouter_Match(t *testing.T) {
+	r := NewRouter()
+	all := r.Match("/**").Check(RequireLogin())
+	admin := r.Match("/admin/**").NotMatch("/admin/login").Check(RequireRole("admin"))
+	order := r.Match("/admin/orders/{id}").Method("post").Check(RequirePermission("order:edit"))
+	public := r.Match("/public/**", "/login").Ignore()
+
+	rule, _ := r.match("GET", "/user/info")
+	assert.Equal(t, all, rule)
+
+	rule, _ = r.match("GET", "/admin/users")
+	assert.Equal(t, admin, rule)
+
+	// 排除的路径由其他规则处理
+	rule, _ = r.match("GET", "/admin/login")
+	assert.Equal(t, all, rule)
+
+	rule, vars := r.match("POST", "/admin/orders/42")
+	assert.Equal(t, order, rule)
+	assert.Equal(t, map[string]string{"id": "42"}, vars)
+
+	rule, _ = r.match("GET", "/admin/orders/42")
+	assert.Equal(t, admin, rule)
+
+	rule, _ = r.match("GET", "/login")
+	assert.Equal(t, public, rule)
+	assert.True(t, rule.ignore)
+
+	empty := NewRouter()
+	empty.Match("/api/**")
+	rule, _ = empty.match("GET", "/static/app.js")
+	assert.Nil(t, rule)
+}
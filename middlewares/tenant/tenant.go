@@ -32,3 +32,9 @@ func New(opts ...Option) app.HandlerFunc {
 func Get(ctx context.Context) string {
 	return cast.ToString(ctx.Value(hertzTenantKey))
 }
+
+// KeyNamespace use as satoken.Config.KeyNamespace to isolate the tokens, sessions and other records by the tenant,
+// the tenant middleware must run before the authentication
+func KeyNamespace(ctx context.Context) string {
+	return Get(ctx)
+}
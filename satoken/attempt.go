@@ -0,0 +1,141 @@
+package satoken
+
+import (
+	"context"
+	"fmt"
+	"github.com/myhaiting/go-fly-lib/bizerr"
+	"github.com/spf13/cast"
+	"math"
+	"time"
+)
+
+const (
+	attemptSubjectAccount = "account"
+	attemptSubjectIp      = "ip"
+)
+
+// 未限制最长锁定时长时锁定时长翻倍的上限，避免溢出
+const unboundedLoginLockTime = time.Duration(math.MaxInt64 / 2)
+
+// LoginLockedError the login is locked after Config.MaxTryTimes failures, errors.Is matches ErrLoginLocked
+type LoginLockedError struct {
+	// Remaining 剩余锁定时长
+	Remaining time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("login locked, retry after %s", e.Remaining.Round(time.Second))
+}
+
+// Unwrap 国际化消息可以使用 {{.Remaining}} 获取剩余锁定秒数
+func (e *LoginLockedError) Unwrap() error {
+	return bizerr.WithData(ErrLoginLocked, map[string]any{
+		"Remaining": int64(math.Ceil(e.Remaining.Seconds())),
+	})
+}
+
+// 登录失败的主体，账号或IP为空时忽略
+func loginAttemptSubjects(account any, ip string) [][2]string {
+	subjects := make([][2]string, 0, 2)
+	if value := cast.ToString(account); value != "" {
+		subjects = append(subjects, [2]string{attemptSubjectAccount, value})
+	}
+	if ip != "" {
+		subjects = append(subjects, [2]string{attemptSubjectIp, ip})
+	}
+	return subjects
+}
+
+// RecordLoginFailure record a failed login of the account and the client ip,
+// both are locked once they fail Config.MaxTryTimes times in a row, each lock doubles the previous one.
+// The store should implement Counter, otherwise the failures are only counted exactly within a Manager instance
+func (m *Manager) RecordLoginFailure(ctx context.Context, account any, ip string) error {
+	if m.getConfigOrGlobal().MaxTryTimes <= 0 {
+		return nil
+	}
+	for _, subject := range loginAttemptSubjects(account, ip) {
+		if err := m.recordLoginFailure(ctx, subject[0], subject[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckLoginAllowed return a *LoginLockedError if the account or the client ip is locked
+func (m *Manager) CheckLoginAllowed(ctx context.Context, account any, ip string) error {
+	var remaining time.Duration
+	for _, subject := range loginAttemptSubjects(account, ip) {
+		ttl, err := m.getStore(ctx).GetTimeout(ctx, m.splicingKeyLoginLock(ctx, subject[0], subject[1]))
+		if err != nil {
+			return err
+		}
+		if ttl > remaining {
+			remaining = ttl
+		}
+	}
+	if remaining > 0 {
+		return &LoginLockedError{Remaining: remaining}
+	}
+	return nil
+}
+
+// ResetLoginFailures clear the failures and the lock of the account and the client ip, usually after a successful login
+func (m *Manager) ResetLoginFailures(ctx context.Context, account any, ip string) error {
+	for _, subject := range loginAttemptSubjects(account, ip) {
+		if err := m.getStore(ctx).Delete(ctx, m.splicingKeyLoginFailure(ctx, subject[0], subject[1])); err != nil {
+			return err
+		}
+		if err := m.getStore(ctx).Delete(ctx, m.splicingKeyLoginLock(ctx, subject[0], subject[1])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 登录失败计数只增不减，每达到最大次数锁定一次，超过最长锁定时长没有失败时过期重新计数
+func (m *Manager) recordLoginFailure(ctx context.Context, subject string, value string) error {
+	failures, err := m.incrLoginFailure(ctx, m.splicingKeyLoginFailure(ctx, subject, value))
+	if err != nil {
+		return err
+	}
+	maxTryTimes := int64(m.getConfigOrGlobal().MaxTryTimes)
+	if failures%maxTryTimes != 0 {
+		return nil
+	}
+	locks := int(failures / maxTryTimes)
+	if lockTime := m.getLoginLockTime(locks); lockTime > 0 {
+		return m.getStore(ctx).Set(ctx, m.splicingKeyLoginLock(ctx, subject, value), cast.ToString(locks), lockTime)
+	}
+	return nil
+}
+
+// 存储支持时原子计数，否则在同一实例内串行计数
+func (m *Manager) incrLoginFailure(ctx context.Context, key string) (int64, error) {
+	exp := m.getConfigOrGlobal().MaxLoginLockTime
+	if counter, ok := m.getStore(ctx).(Counter); ok {
+		return counter.Incr(ctx, key, exp)
+	}
+	m.attemptMu.Lock()
+	defer m.attemptMu.Unlock()
+	record, err := m.getStore(ctx).Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	failures := cast.ToInt64(record) + 1
+	return failures, m.getStore(ctx).Set(ctx, key, cast.ToString(failures), exp)
+}
+
+// 第 locks 次锁定的时长
+func (m *Manager) getLoginLockTime(locks int) time.Duration {
+	lockTime, maxLockTime := m.getConfigOrGlobal().LoginLockTime, m.getConfigOrGlobal().MaxLoginLockTime
+	if maxLockTime <= 0 {
+		maxLockTime = unboundedLoginLockTime
+	}
+	for i := 1; i < locks && lockTime > 0 && lockTime < maxLockTime; i++ {
+		lockTime *= 2
+	}
+	if lockTime > maxLockTime {
+		return maxLockTime
+	}
+	return lockTime
+}
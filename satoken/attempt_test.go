@@ -0,0 +1,83 @@
+package satoken_test
+
+import (
+	"context"
+	"errors"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/myhaiting/go-fly-lib/satoken/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManager_LoginAttempt(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.MaxTryTimes = 3
+	cfg.LoginLockTime = 40 * time.Millisecond
+	cfg.MaxLoginLockTime = time.Minute
+	mgr := newTestManager(t, cfg)
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, mgr.RecordLoginFailure(ctx, "alice", "10.0.0.1"))
+	}
+	assert.NoError(t, mgr.CheckLoginAllowed(ctx, "alice", "10.0.0.1"))
+
+	assert.NoError(t, mgr.RecordLoginFailure(ctx, "alice", "10.0.0.1"))
+	err := mgr.CheckLoginAllowed(ctx, "alice", "")
+	assert.ErrorIs(t, err, satoken.ErrLoginLocked)
+	var locked *satoken.LoginLockedError
+	assert.True(t, errors.As(err, &locked))
+	assert.True(t, locked.Remaining > 0 && locked.Remaining <= 40*time.Millisecond)
+	// 同一IP登录其他账号也被锁定
+	assert.ErrorIs(t, mgr.CheckLoginAllowed(ctx, "bob", "10.0.0.1"), satoken.ErrLoginLocked)
+	assert.NoError(t, mgr.CheckLoginAllowed(ctx, "bob", "10.0.0.2"))
+
+	// 再次锁定时长翻倍
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, mgr.CheckLoginAllowed(ctx, "alice", "10.0.0.1"))
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, mgr.RecordLoginFailure(ctx, "alice", ""))
+	}
+	assert.True(t, errors.As(mgr.CheckLoginAllowed(ctx, "alice", ""), &locked))
+	assert.True(t, locked.Remaining > 40*time.Millisecond)
+
+	assert.NoError(t, mgr.ResetLoginFailures(ctx, "alice", ""))
+	assert.NoError(t, mgr.CheckLoginAllowed(ctx, "alice", ""))
+}
+
+func TestManager_LoginAttemptUnbounded(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.MaxTryTimes = 1
+	cfg.LoginLockTime = time.Hour
+	// 不限制最长锁定时长，多次翻倍后仍然锁定
+	cfg.MaxLoginLockTime = 0
+	mgr := satoken.NewDefaultManager()
+	mgr.SetCfg(cfg)
+	s := store.NewMemoryStore()
+	defer s.Close()
+	mgr.MapTokenStorage(s)
+
+	var locked *satoken.LoginLockedError
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, mgr.RecordLoginFailure(ctx, "alice", ""))
+		assert.True(t, errors.As(mgr.CheckLoginAllowed(ctx, "alice", ""), &locked))
+		assert.True(t, locked.Remaining > 59*time.Minute)
+		// 删除本次锁定，下次失败需要重新锁定
+		assert.NoError(t, s.Delete(ctx, "satoken:login:login-lock:account:alice"))
+	}
+}
+
+func TestManager_LoginAttemptConcurrent(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.MaxTryTimes = 20
+	// 两个实例共享存储，计数不能丢失
+	mgrs := newTestManagers(t, cfg)
+	recorded := runConcurrent(mgrs, cfg.MaxTryTimes, func(mgr *satoken.Manager) error {
+		return mgr.RecordLoginFailure(ctx, "alice", "")
+	})
+	assert.Equal(t, cfg.MaxTryTimes, recorded)
+	assert.ErrorIs(t, mgrs[0].CheckLoginAllowed(ctx, "alice", ""), satoken.ErrLoginLocked)
+}
@@ -0,0 +1,91 @@
+package satoken
+
+import (
+	"context"
+	"errors"
+)
+
+type batchKey struct{}
+
+// 一次批量操作的状态
+type batchState struct {
+	batch Batch
+	// 批次中读取、创建或删除的Session，删除后为 nil，保证批次内读到自己的修改
+	sessions map[string]*Session
+	// 提交后再通知的监听事件
+	events []func()
+	done   bool
+}
+
+func getBatchState(ctx context.Context) *batchState {
+	state, ok := ctx.Value(batchKey{}).(*batchState)
+	if !ok || state.done {
+		return nil
+	}
+	return state
+}
+
+// 获取当前使用的存储，批量操作中返回批次
+func (m *Manager) getStore(ctx context.Context) TokenStore {
+	if state := getBatchState(ctx); state != nil {
+		return state.batch
+	}
+	return m.tokenStore
+}
+
+// 在一个批次中执行 fn，存储实现 Batcher 时 fn 的写入原子提交，Session 版本冲突时重新执行，
+// 监听器在提交后通知
+func (m *Manager) runBatch(ctx context.Context, fn func(ctx context.Context) error) error {
+	batcher, ok := m.tokenStore.(Batcher)
+	if !ok || getBatchState(ctx) != nil {
+		return fn(ctx)
+	}
+	for i := 0; ; i++ {
+		state := &batchState{
+			batch:    batcher.Batch(ctx),
+			sessions: make(map[string]*Session),
+		}
+		err := fn(context.WithValue(ctx, batchKey{}, state))
+		if err == nil {
+			err = state.batch.Commit(ctx)
+		}
+		state.done = true
+		if errors.Is(err, ErrSessionConflict) && i < maxSaveRetries {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, event := range state.events {
+			event()
+		}
+		return nil
+	}
+}
+
+// 存储的批次是否原子地校验 Batch.Expect
+func (m *Manager) isAtomicBatch() bool {
+	batcher, ok := m.tokenStore.(Batcher)
+	if !ok {
+		return false
+	}
+	if atomic, ok := batcher.(AtomicBatcher); ok {
+		return atomic.IsAtomicBatch()
+	}
+	return true
+}
+
+// 读取批次中缓存的Session，ok 为 false 表示未缓存
+func getBatchSession(ctx context.Context, sessionId string) (sess *Session, ok bool) {
+	if state := getBatchState(ctx); state != nil {
+		sess, ok = state.sessions[sessionId]
+	}
+	return sess, ok
+}
+
+// 缓存批次中的Session，sess 为 nil 表示已删除
+func setBatchSession(ctx context.Context, sessionId string, sess *Session) {
+	if state := getBatchState(ctx); state != nil {
+		state.sessions[sessionId] = sess
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/google/uuid"
 	"github.com/spf13/cast"
 	"strings"
+	"time"
 )
 
 const (
@@ -23,42 +23,138 @@ func (m *Manager) getConfigOrGlobal() *Config {
 	return m.cfg
 }
 
-func (m *Manager) splicingKeySession(loginId any) string {
-	return fmt.Sprintf("%s:%s:session:%s", m.getConfigOrGlobal().TokenName, m.loginType, cast.ToString(loginId))
+// 每次请求都可能写入的键类型
+const (
+	keyKindLastActive   = "last-active"
+	keyKindLoginFailure = "login-failure"
+)
+
+// 拼接存储键：[KeyPrefix:][命名空间:]TokenName:loginType:kind:parts...
+func (m *Manager) splicingKey(ctx context.Context, kind string, parts ...string) string {
+	cfg := m.getConfigOrGlobal()
+	var builder strings.Builder
+	if cfg.KeyPrefix != "" {
+		builder.WriteString(cfg.KeyPrefix)
+		builder.WriteByte(':')
+	}
+	if cfg.KeyNamespace != nil {
+		if namespace := cfg.KeyNamespace(ctx); namespace != "" {
+			builder.WriteString(escapeKeyNamespace(namespace))
+			builder.WriteByte(':')
+		}
+	}
+	builder.WriteString(cfg.TokenName)
+	builder.WriteByte(':')
+	builder.WriteString(m.loginType)
+	builder.WriteByte(':')
+	builder.WriteString(kind)
+	for _, part := range parts {
+		builder.WriteByte(':')
+		builder.WriteString(part)
+	}
+	return builder.String()
+}
+
+// IsHotPathKey whether the store key of the manager may be written on every request, the last active time
+// and the login failure count, pass it to store.WithCacheBypass so a CacheStore neither caches nor broadcasts them
+func (m *Manager) IsHotPathKey(key string) bool {
+	for _, kind := range []string{keyKindLastActive, keyKindLoginFailure} {
+		// 键前可能有前缀和命名空间
+		infix := m.getConfigOrGlobal().TokenName + ":" + m.loginType + ":" + kind + ":"
+		if strings.HasPrefix(key, infix) || strings.Contains(key, ":"+infix) {
+			return true
+		}
+	}
+	return false
+}
+
+// 命名空间通常来自请求，转义分隔符避免构造出其他命名空间的键
+var keyNamespaceEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+func escapeKeyNamespace(namespace string) string {
+	return keyNamespaceEscaper.Replace(namespace)
+}
+
+func (m *Manager) splicingKeySession(ctx context.Context, loginId any) string {
+	return m.splicingKey(ctx, "session", cast.ToString(loginId))
+}
+
+func (m *Manager) splicingKeyTokenValue(ctx context.Context, tokenValue string) string {
+	return m.splicingKey(ctx, "token", tokenValue)
+}
+
+func (m *Manager) splicingKeyTokenSession(ctx context.Context, tokenValue string) string {
+	return m.splicingKey(ctx, "token-session", tokenValue)
+}
+
+func (m *Manager) splicingKeyLastActiveTime(ctx context.Context, tokenValue string) string {
+	return m.splicingKey(ctx, keyKindLastActive, tokenValue)
 }
 
-func (m *Manager) splicingKeyTokenValue(tokenValue string) string {
-	return fmt.Sprintf("%s:%s:token:%s", m.getConfigOrGlobal().TokenName, m.loginType, tokenValue)
+func (m *Manager) splicingKeyDisable(ctx context.Context, loginId any, service string) string {
+	return m.splicingKey(ctx, "disable", service, cast.ToString(loginId))
 }
 
-func (m *Manager) splicingKeyTokenSession(tokenValue string) string {
-	return fmt.Sprintf("%s:%s:token-session:%s", m.getConfigOrGlobal().TokenName, m.loginType, tokenValue)
+func (m *Manager) splicingKeySafe(ctx context.Context, tokenValue string, service string) string {
+	return m.splicingKey(ctx, "safe", service, tokenValue)
 }
 
-func (m *Manager) splicingKeyLastActiveTime(tokenValue string) string {
-	return fmt.Sprintf("%s:%s:last-active:%s", m.getConfigOrGlobal().TokenName, m.loginType, tokenValue)
+func (m *Manager) splicingKeyTempToken(ctx context.Context, tokenValue string) string {
+	return m.splicingKey(ctx, "temp-token", tokenValue)
 }
 
-func (m *Manager) splicingKeyDisable(loginId any, service string) string {
-	return fmt.Sprintf("%s:%s:disable:%s:%s", m.getConfigOrGlobal().TokenName, m.loginType, service, cast.ToString(loginId))
+func (m *Manager) splicingKeyLoginFailure(ctx context.Context, subject string, value string) string {
+	return m.splicingKey(ctx, keyKindLoginFailure, subject, value)
 }
 
-func (m *Manager) splicingKeySafe(tokenValue string, service string) string {
-	return fmt.Sprintf("%s:%s:safe:%s:%s", m.getConfigOrGlobal().TokenName, m.loginType, service, tokenValue)
+func (m *Manager) splicingKeyLoginLock(ctx context.Context, subject string, value string) string {
+	return m.splicingKey(ctx, "login-lock", subject, value)
 }
 
 func (m *Manager) getSession(ctx context.Context, sessionId string) (*Session, error) {
+	// 批次中读取已修改的Session
+	if cached, ok := getBatchSession(ctx, sessionId); ok {
+		if cached == nil {
+			return nil, ErrObjectNotExist
+		}
+		return cached, nil
+	}
 	var sess Session
-	if err := m.tokenStore.GetObj(ctx, sessionId, &sess); err != nil {
+	if err := m.getStore(ctx).GetObj(ctx, sessionId, &sess); err != nil {
 		return nil, err
 	}
-	sess.store = m.tokenStore
+	sess.store = m.getStore(ctx)
 	sess.ctx = ctx
+	setBatchSession(ctx, sessionId, &sess)
 	return &sess, nil
 }
 
+// 删除Session，Session不存在时不删除也不通知
 func (m *Manager) deleteSession(ctx context.Context, sessionId string) error {
-	return m.tokenStore.DeleteObj(ctx, sessionId)
+	exists, err := m.existsSession(ctx, sessionId)
+	if err != nil || !exists {
+		return err
+	}
+	if err = m.getStore(ctx).DeleteObj(ctx, sessionId); err != nil {
+		return err
+	}
+	setBatchSession(ctx, sessionId, nil)
+	m.notify(ctx, func(l Listener) {
+		l.OnDeleteSession(ctx, sessionId)
+	})
+	return nil
+}
+
+// Session是否存在，批次中已读取或已删除的Session不再读取存储
+func (m *Manager) existsSession(ctx context.Context, sessionId string) (bool, error) {
+	if cached, ok := getBatchSession(ctx, sessionId); ok {
+		return cached != nil, nil
+	}
+	ttl, err := m.getStore(ctx).GetObjTimeout(ctx, sessionId)
+	if err != nil {
+		return false, err
+	}
+	return ttl >= 0 || ttl == NeverExpire, nil
 }
 
 func (m *Manager) createLoginSession(ctx context.Context, loginId any, model LoginModel) (string, error) {
@@ -66,24 +162,70 @@ func (m *Manager) createLoginSession(ctx context.Context, loginId any, model Log
 	if err != nil {
 		return "", err
 	}
+	timeout := model.getTimeoutOrDefault(m.getConfigOrGlobal().Timeout)
 	sess, err := m.getSessionByLoginId(ctx, loginId, true)
 	if err != nil {
 		return "", err
 	}
-	sess.addTokenSign(TokenSign{
-		Value:  tokenValue,
-		Device: model.getDeviceOrDefault(),
-		Tag:    "",
+	now := time.Now().UnixMilli()
+	sign := TokenSign{
+		Value:          tokenValue,
+		Device:         model.getDeviceOrDefault(),
+		Tag:            "",
+		Timeout:        timeout,
+		CreateTime:     now,
+		RefreshFamily:  model.refreshFamily,
+		Ip:             model.Ip,
+		UserAgent:      model.UserAgent,
+		LastActiveTime: now,
+		Attrs:          model.Attrs,
+	}
+	sess.addTokenSign(sign)
+	// 并发登录时在最新的Session上重新添加
+	err = sess.SaveWithMerge(func(latest *Session) error {
+		latest.addTokenSign(sign)
+		return nil
 	})
-	if err = sess.Save(); err != nil {
+	if err != nil {
+		return "", err
+	}
+	// 账号Session不能早于其Token过期
+	if err = m.extendSessionTimeout(ctx, sess, timeout); err != nil {
 		return "", err
 	}
-	if err = m.tokenStore.Set(ctx, m.splicingKeyTokenValue(tokenValue), cast.ToString(loginId), m.getConfigOrGlobal().Timeout); err != nil {
+	if err = m.getStore(ctx).Set(ctx, m.splicingKeyTokenValue(ctx, tokenValue), cast.ToString(loginId), storeTimeout(timeout)); err != nil {
+		return "", err
+	}
+	if err = m.setLastActiveToStore(ctx, tokenValue, model.getActiveTimeout(), storeTimeout(timeout)); err != nil {
+		return "", err
+	}
+	// 超过最大登录数量时顶掉最早的登录
+	if err = m.replacedByMaxLoginCount(ctx, loginId, sess); err != nil {
 		return "", err
 	}
 	return tokenValue, nil
 }
 
+// 延长Session有效期至 timeout，已有有效期更长时不变
+func (m *Manager) extendSessionTimeout(ctx context.Context, sess *Session, timeout time.Duration) error {
+	ttl, err := m.getStore(ctx).GetObjTimeout(ctx, sess.Id)
+	if err != nil {
+		return err
+	}
+	// 已经永不过期
+	if ttl == NeverExpire {
+		return nil
+	}
+	if timeout < 0 {
+		// 存储没有取消过期的方法，重新写入
+		return m.getStore(ctx).SetObj(ctx, sess.Id, sess, 0)
+	}
+	if ttl < timeout {
+		return m.getStore(ctx).UpdateObjTimeout(ctx, sess.Id, timeout)
+	}
+	return nil
+}
+
 func (m *Manager) setTokenValue(ctx context.Context, tokenValue string) error {
 	return nil
 }
@@ -114,7 +256,7 @@ func (m *Manager) genTokenValue(ctx context.Context, loginId any, model LoginMod
 			}
 		}
 	}
-	return m.createTokenValue()
+	return m.createTokenValue(ctx, loginId, model)
 }
 
 // 顶人下线，根据账号id 和 设备类型
@@ -126,21 +268,44 @@ func (m *Manager) replaced(ctx context.Context, loginId any, device string) erro
 		}
 		return err
 	}
+	return m.replaceTokenSigns(ctx, loginId, sess, sess.getTokenSignListByDevice(device))
+}
 
-	signList := sess.getTokenSignListByDevice(device)
+// 顶人下线，保留最近登录的 MaxLoginCount 个Token
+func (m *Manager) replacedByMaxLoginCount(ctx context.Context, loginId any, sess *Session) error {
+	maxLoginCount := m.getConfigOrGlobal().MaxLoginCount
+	if !m.getConfigOrGlobal().IsConcurrent || maxLoginCount <= 0 {
+		return nil
+	}
+	signList := sess.getTokenSignListByCreateTime()
+	if len(signList) <= maxLoginCount {
+		return nil
+	}
+	return m.replaceTokenSigns(ctx, loginId, sess, signList[:len(signList)-maxLoginCount])
+}
 
+// 移除Token签名并将Token映射标记为被顶下线
+func (m *Manager) replaceTokenSigns(ctx context.Context, loginId any, sess *Session, signList []*TokenSign) error {
 	for _, sign := range signList {
 		sess.removeTokenSign(sign.Value)
 		// save session
-		if err = sess.Save(); err != nil {
+		if err := m.saveSessionRemoving(sess, sign.Value); err != nil {
 			return err
 		}
 		// update token mapping
-		if err = m.tokenStore.Update(ctx, m.splicingKeyTokenValue(sign.Value), BE_REPLACED); err != nil {
+		if err := m.getStore(ctx).Update(ctx, m.splicingKeyTokenValue(ctx, sign.Value), BE_REPLACED); err != nil {
+			return err
+		}
+		if err := m.deleteLastActive(ctx, sign.Value); err != nil {
 			return err
 		}
+		if err := m.deleteRefreshFamily(ctx, sign.RefreshFamily); err != nil {
+			return err
+		}
+		m.notify(ctx, func(l Listener) {
+			l.OnReplaced(ctx, m.loginType, loginId, sign.Value, sign.Device)
+		})
 	}
-
 	return nil
 }
 
@@ -166,16 +331,259 @@ func (m *Manager) getTokenValueListByLoginId(ctx context.Context, loginId any, d
 	return sess.getTokenValueListByDevice(device)
 }
 
+// 账号Session没有Token时删除，否则保存，removed 为本次移除的Token
+func (m *Manager) saveOrDeleteSession(ctx context.Context, sess *Session, removed ...string) error {
+	if len(sess.TokenSignList) == 0 {
+		return m.deleteSession(ctx, sess.Id)
+	}
+	if err := m.saveSessionRemoving(sess, removed...); err != nil {
+		return err
+	}
+	// 合并后可能已没有Token
+	if len(sess.TokenSignList) == 0 {
+		return m.deleteSession(ctx, sess.Id)
+	}
+	return nil
+}
+
+// 保存账号Session，并发修改时在最新的Session上重新移除 removed 中的Token
+func (m *Manager) saveSessionRemoving(sess *Session, removed ...string) error {
+	return sess.SaveWithMerge(func(latest *Session) error {
+		for _, tokenValue := range removed {
+			latest.removeTokenSign(tokenValue)
+		}
+		return nil
+	})
+}
+
+// 将Token映射标记为被踢下线，并清理Token相关数据
+func (m *Manager) kickoutToken(ctx context.Context, tokenValue string) error {
+	if err := m.getStore(ctx).Update(ctx, m.splicingKeyTokenValue(ctx, tokenValue), KICK_OUT); err != nil {
+		return err
+	}
+	if err := m.deleteTokenSession(ctx, tokenValue); err != nil {
+		return err
+	}
+	return m.deleteLastActive(ctx, tokenValue)
+}
+
 func (m *Manager) deleteTokenToIdMapping(ctx context.Context, tokenValue string) error {
-	return m.tokenStore.Delete(ctx, m.splicingKeyTokenValue(tokenValue))
+	return m.getStore(ctx).Delete(ctx, m.splicingKeyTokenValue(ctx, tokenValue))
 }
 
 func (m *Manager) deleteTokenSession(ctx context.Context, tokenValue string) error {
-	return m.tokenStore.Delete(ctx, m.splicingKeyTokenSession(tokenValue))
+	return m.deleteSession(ctx, m.splicingKeyTokenSession(ctx, tokenValue))
+}
+
+// 是否开启全局活跃超时
+func (m *Manager) isOpenActiveTimeout() bool {
+	return m.getConfigOrGlobal().ActiveTimeout > 0
+}
+
+// 是否校验活跃超时，全局活跃超时和动态活跃超时都关闭时不读取最近活跃时间
+func (m *Manager) isCheckActiveTimeout() bool {
+	return m.isOpenActiveTimeout() || m.getConfigOrGlobal().DynamicActiveTimeout
+}
+
+// 写入最近活跃时间，activeTimeout 为本次登录指定的活跃超时，0 表示使用全局配置
+func (m *Manager) setLastActiveToStore(ctx context.Context, tokenValue string, activeTimeout, timeout time.Duration) error {
+	if !m.isOpenActiveTimeout() && (activeTimeout <= 0 || !m.getConfigOrGlobal().DynamicActiveTimeout) {
+		return nil
+	}
+	value := cast.ToString(time.Now().UnixMilli())
+	if activeTimeout != 0 {
+		value += "," + cast.ToString(activeTimeout.Milliseconds())
+	}
+	return m.getStore(ctx).Set(ctx, m.splicingKeyLastActiveTime(ctx, tokenValue), value, timeout)
+}
+
+// 读取最近活跃时间及生效的活跃超时，记录不存在时 exists 为 false
+func (m *Manager) getLastActive(ctx context.Context, tokenValue string) (lastActive int64, activeTimeout time.Duration, exists bool, err error) {
+	value, err := m.getStore(ctx).Get(ctx, m.splicingKeyLastActiveTime(ctx, tokenValue))
+	if err != nil || value == "" {
+		return 0, 0, false, err
+	}
+	lastActive, activeTimeout = m.parseLastActive(value)
+	return lastActive, activeTimeout, true, nil
+}
+
+// 解析 "最近活跃时间[,活跃超时]"
+func (m *Manager) parseLastActive(value string) (int64, time.Duration) {
+	activeTimeout := m.getConfigOrGlobal().ActiveTimeout
+	parts := strings.SplitN(value, ",", 2)
+	if len(parts) == 2 {
+		activeTimeout = time.Duration(cast.ToInt64(parts[1])) * time.Millisecond
+	}
+	return cast.ToInt64(parts[0]), activeTimeout
+}
+
+// 最近活跃时间的最小写入间隔，间隔内的请求不再写入，活跃超时最多因此提前该间隔
+func lastActiveUpdateInterval(activeTimeout time.Duration) time.Duration {
+	if interval := activeTimeout / 10; interval < time.Minute {
+		return interval
+	}
+	return time.Minute
+}
+
+// 校验活跃超时并刷新最近活跃时间，记录只读取一次，距上次写入不足写入间隔时不写入
+func (m *Manager) checkAndTouchActive(ctx context.Context, tokenValue string) error {
+	if !m.isCheckActiveTimeout() {
+		return nil
+	}
+	key := m.splicingKeyLastActiveTime(ctx, tokenValue)
+	value, err := m.getStore(ctx).Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		// 开启全局活跃超时前登录的Token没有记录，从现在开始计算
+		if m.isOpenActiveTimeout() {
+			return m.startLastActive(ctx, tokenValue)
+		}
+		return nil
+	}
+	lastActive, activeTimeout := m.parseLastActive(value)
+	if activeTimeout <= 0 {
+		return nil
+	}
+	now := time.Now().UnixMilli()
+	elapsed := time.Duration(now-lastActive) * time.Millisecond
+	if elapsed > activeTimeout {
+		return ErrTokenActiveTimeout
+	}
+	if elapsed < lastActiveUpdateInterval(activeTimeout) {
+		return nil
+	}
+	// 保留原有的过期时间和活跃超时
+	updated := cast.ToString(now)
+	if index := strings.Index(value, ","); index >= 0 {
+		updated += value[index:]
+	}
+	return m.getStore(ctx).Update(ctx, key, updated)
+}
+
+// 为没有记录的Token写入最近活跃时间，与Token同时过期
+func (m *Manager) startLastActive(ctx context.Context, tokenValue string) error {
+	ttl, err := m.getStore(ctx).GetTimeout(ctx, m.splicingKeyTokenValue(ctx, tokenValue))
+	if err != nil {
+		return err
+	}
+	if ttl != NeverExpire && ttl <= 0 {
+		return nil
+	}
+	return m.setLastActiveToStore(ctx, tokenValue, 0, storeTimeout(ttl))
+}
+
+func (m *Manager) deleteLastActive(ctx context.Context, tokenValue string) error {
+	return m.getStore(ctx).Delete(ctx, m.splicingKeyLastActiveTime(ctx, tokenValue))
+}
+
+// 剩余有效期低于阈值时续期
+func (m *Manager) renewTimeoutIfNecessary(ctx context.Context, tokenValue string, loginId string) error {
+	ttl, err := m.getStore(ctx).GetTimeout(ctx, m.splicingKeyTokenValue(ctx, tokenValue))
+	if err != nil {
+		return err
+	}
+	// 永不过期或已不存在
+	if ttl < 0 {
+		return nil
+	}
+	// 按登录时指定的有效期计算阈值并续期
+	timeout, err := m.getTokenTimeout(ctx, tokenValue, loginId)
+	if err != nil {
+		return err
+	}
+	if timeout <= 0 || ttl >= m.getRenewThreshold(timeout) {
+		return nil
+	}
+	return m.renewTimeout(ctx, tokenValue, loginId, timeout)
+}
+
+func (m *Manager) getRenewThreshold(timeout time.Duration) time.Duration {
+	if threshold := m.getConfigOrGlobal().RenewThreshold; threshold > 0 {
+		return threshold
+	}
+	return timeout / 2
+}
+
+// 获取Token登录时的有效期
+func (m *Manager) getTokenTimeout(ctx context.Context, tokenValue string, loginId string) (time.Duration, error) {
+	sess, err := m.getSessionByLoginId(ctx, loginId, false)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return m.getConfigOrGlobal().Timeout, nil
+		}
+		return 0, err
+	}
+	sess.Lock()
+	defer sess.Unlock()
+	if _, sign := sess.getTokenSign(tokenValue); sign != nil && sign.Timeout != 0 {
+		return sign.Timeout, nil
+	}
+	return m.getConfigOrGlobal().Timeout, nil
+}
+
+// 续期Token映射、最近活跃时间、Token Session，账号Session只延长不缩短
+func (m *Manager) renewTimeout(ctx context.Context, tokenValue string, loginId string, timeout time.Duration) error {
+	if err := m.getStore(ctx).UpdateTimeout(ctx, m.splicingKeyTokenValue(ctx, tokenValue), timeout); err != nil {
+		return err
+	}
+	if err := m.getStore(ctx).UpdateTimeout(ctx, m.splicingKeyLastActiveTime(ctx, tokenValue), timeout); err != nil {
+		return err
+	}
+	if err := m.getStore(ctx).UpdateObjTimeout(ctx, m.splicingKeyTokenSession(ctx, tokenValue), timeout); err != nil {
+		return err
+	}
+	sessionId := m.splicingKeySession(ctx, loginId)
+	ttl, err := m.getStore(ctx).GetObjTimeout(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	if ttl >= 0 && ttl < timeout {
+		if err = m.getStore(ctx).UpdateObjTimeout(ctx, sessionId, timeout); err != nil {
+			return err
+		}
+	}
+	if err = m.touchTokenSign(ctx, loginId, tokenValue); err != nil {
+		return err
+	}
+	m.notify(ctx, func(l Listener) {
+		l.OnRenewTimeout(ctx, m.loginType, loginId, tokenValue, timeout)
+	})
+	return nil
+}
+
+// 续期时刷新账号Session中Token签名的最近活跃时间，用于设备列表展示
+func (m *Manager) touchTokenSign(ctx context.Context, loginId string, tokenValue string) error {
+	sess, err := m.getSessionByLoginId(ctx, loginId, false)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return nil
+		}
+		return err
+	}
+	now := time.Now().UnixMilli()
+	touch := func(s *Session) bool {
+		s.Lock()
+		defer s.Unlock()
+		_, sign := s.getTokenSign(tokenValue)
+		if sign == nil {
+			return false
+		}
+		sign.LastActiveTime = now
+		return true
+	}
+	if !touch(sess) {
+		return nil
+	}
+	return sess.SaveWithMerge(func(latest *Session) error {
+		touch(latest)
+		return nil
+	})
 }
 
 func (m *Manager) getLoginIdNotHandle(ctx context.Context, tokenValue string) string {
-	loginId, err := m.tokenStore.Get(ctx, m.splicingKeyTokenValue(tokenValue))
+	loginId, err := m.getStore(ctx).Get(ctx, m.splicingKeyTokenValue(ctx, tokenValue))
 	if err != nil {
 		return ""
 	}
@@ -183,7 +591,7 @@ func (m *Manager) getLoginIdNotHandle(ctx context.Context, tokenValue string) st
 }
 
 func (m *Manager) getSessionByLoginId(ctx context.Context, loginId any, isCreate bool) (*Session, error) {
-	return m.getSessionBySessionId(ctx, m.splicingKeySession(loginId), isCreate, func(sess *Session) {
+	return m.getSessionBySessionId(ctx, m.splicingKeySession(ctx, loginId), isCreate, m.getConfigOrGlobal().Timeout, func(sess *Session) {
 		sess.Type = SessionTypeAccount
 		sess.LoginType = m.loginType
 		sess.LoginId = loginId
@@ -191,28 +599,52 @@ func (m *Manager) getSessionByLoginId(ctx context.Context, loginId any, isCreate
 }
 
 func (m *Manager) getTokenSessionByToken(ctx context.Context, tokenValue string, isCreate bool) (*Session, error) {
-	return m.getSessionBySessionId(ctx, m.splicingKeyTokenSession(tokenValue), isCreate, func(sess *Session) {
+	// Token Session与Token同时过期
+	timeout := m.getConfigOrGlobal().Timeout
+	if isCreate {
+		ttl, err := m.getStore(ctx).GetTimeout(ctx, m.splicingKeyTokenValue(ctx, tokenValue))
+		if err != nil {
+			return nil, err
+		}
+		if ttl > 0 || ttl == NeverExpire {
+			timeout = ttl
+		}
+	}
+	return m.getSessionBySessionId(ctx, m.splicingKeyTokenSession(ctx, tokenValue), isCreate, timeout, func(sess *Session) {
 		sess.Type = SessionTypeToken
 		sess.LoginType = m.loginType
 		sess.Token = tokenValue
 	})
 }
 
-func (m *Manager) getSessionBySessionId(ctx context.Context, sessionId string, isCreate bool, callback func(*Session)) (*Session, error) {
+func (m *Manager) getSessionBySessionId(ctx context.Context, sessionId string, isCreate bool, timeout time.Duration, callback func(*Session)) (*Session, error) {
 	if sessionId == "" {
 		return nil, fmt.Errorf("session id is empty")
 	}
 	sess, err := m.getSession(ctx, sessionId)
 	if err != nil {
 		if errors.Is(err, ErrObjectNotExist) && isCreate {
-			sess = newSession(sessionId, m.tokenStore)
+			sess = newSession(sessionId, m.getStore(ctx))
 			sess.ctx = ctx
 			if callback != nil {
 				callback(sess)
 			}
-			if err = m.tokenStore.SetObj(ctx, sessionId, sess, m.getConfigOrGlobal().Timeout); err != nil {
+			if versioned, ok := m.getStore(ctx).(VersionedStore); ok {
+				err = versioned.CreateObj(ctx, sessionId, sess, storeTimeout(timeout))
+				// 并发创建时使用已创建的Session，批次中的冲突在提交时返回并重新执行
+				if errors.Is(err, ErrSessionConflict) {
+					return m.getSession(ctx, sessionId)
+				}
+			} else {
+				err = m.getStore(ctx).SetObj(ctx, sessionId, sess, storeTimeout(timeout))
+			}
+			if err != nil {
 				return nil, err
 			}
+			setBatchSession(ctx, sessionId, sess)
+			m.notify(ctx, func(l Listener) {
+				l.OnCreateSession(ctx, sessionId)
+			})
 			return sess, nil
 		}
 		return nil, err
@@ -239,8 +671,3 @@ func (m *Manager) isValidLoginId(loginId string) error {
 	}
 	return nil
 }
-
-// 生成Token值
-func (m *Manager) createTokenValue() (string, error) {
-	return strings.ReplaceAll(uuid.New().String(), "-", ""), nil
-}
@@ -1,6 +1,20 @@
 package satoken
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// NeverExpire Token 永不过期，与存储 TTL 返回的永不过期值一致
+const NeverExpire time.Duration = -1
+
+// 转换为存储使用的过期时间，存储以 0 表示永不过期
+func storeTimeout(timeout time.Duration) time.Duration {
+	if timeout < 0 {
+		return 0
+	}
+	return timeout
+}
 
 // Config authorization configuration parameters
 type Config struct {
@@ -14,14 +28,35 @@ type Config struct {
 	MaxTryTimes       int
 	DataRefreshPeriod int
 	AutoRenew         bool
+	// DynamicActiveTimeout 是否启用登录时指定的活跃超时 LoginModel.ActiveTimeout，
+	// 全局活跃超时关闭时只有开启后才读取最近活跃时间
+	DynamicActiveTimeout bool
+	// IsCheckDisable 是否在 GetLoginId 中校验账号封禁，开启后每次请求多读取一次存储，
+	// 关闭时被封禁的账号只是无法登录，已登录的Token需要踢下线
+	IsCheckDisable bool
+	// RenewThreshold 自动续期阈值，Token 剩余有效期低于该值时才续期，0 表示 Timeout 的一半
+	RenewThreshold time.Duration
+	// RefreshTimeout 刷新Token有效期，每次刷新重新计算，NeverExpire 表示永不过期
+	RefreshTimeout time.Duration
+	// LoginLockTime 首次锁定时长，之后每次锁定翻倍
+	LoginLockTime time.Duration
+	// MaxLoginLockTime 最长锁定时长，超过该时长没有登录失败时重新计算
+	MaxLoginLockTime time.Duration
+	// KeyPrefix 所有存储键的前缀，多个应用共用存储时区分，为空不加前缀
+	KeyPrefix string
+	// KeyNamespace 按请求返回存储键的命名空间，如租户 tenant.KeyNamespace，返回空时使用公共的键
+	KeyNamespace func(ctx context.Context) string
 }
 
 // NewDefaultConfig create to default config
 func NewDefaultConfig() *Config {
 	return &Config{
-		TokenName:     "satoken",
-		TokenStyle:    "uuid",
-		Timeout:       30 * time.Minute,
-		ActiveTimeout: -1,
+		TokenName:        "satoken",
+		TokenStyle:       "uuid",
+		Timeout:          30 * time.Minute,
+		ActiveTimeout:    -1,
+		RefreshTimeout:   7 * 24 * time.Hour,
+		LoginLockTime:    time.Minute,
+		MaxLoginLockTime: 24 * time.Hour,
 	}
 }
@@ -0,0 +1,58 @@
+package satoken
+
+import (
+	"context"
+	"errors"
+	"github.com/myhaiting/go-fly-lib/bizerr"
+)
+
+// ListDevices list the logged in devices of the account, earliest login first.
+// LastActiveTime is the time of the login or the latest renewal,
+// or the latest request if the active timeout is enabled
+func (m *Manager) ListDevices(ctx context.Context, loginId any) ([]*TokenSign, error) {
+	if m.isJwtStateless() {
+		return nil, ErrJwtStateless
+	}
+	sess, err := m.getSessionByLoginId(ctx, loginId, false)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return []*TokenSign{}, nil
+		}
+		return nil, err
+	}
+	signList := sess.getTokenSignListByCreateTime()
+	devices := make([]*TokenSign, 0, len(signList))
+	for _, item := range signList {
+		device := *item
+		lastActive, _, exists, err := m.getLastActive(ctx, item.Value)
+		if err != nil {
+			return nil, err
+		}
+		if exists && lastActive > device.LastActiveTime {
+			device.LastActiveTime = lastActive
+		}
+		devices = append(devices, &device)
+	}
+	return devices, nil
+}
+
+// LogoutDevice logout one device of the account, return ErrNoToken if the token does not belong to the account
+func (m *Manager) LogoutDevice(ctx context.Context, loginId any, tokenValue string) error {
+	if m.isJwtStateless() {
+		return ErrJwtStateless
+	}
+	sess, err := m.getSessionByLoginId(ctx, loginId, false)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return bizerr.WrapBizError(ctx, ErrNoToken)
+		}
+		return err
+	}
+	sess.Lock()
+	_, sign := sess.getTokenSign(tokenValue)
+	sess.Unlock()
+	if sign == nil {
+		return bizerr.WrapBizError(ctx, ErrNoToken)
+	}
+	return m.LogoutByToken(ctx, tokenValue)
+}
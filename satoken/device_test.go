@@ -0,0 +1,50 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManager_ListDevices(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := newTestManager(t, cfg)
+
+	pc, _ := mgr.Login(ctx, 10001, satoken.LoginModel{
+		Device:    "pc",
+		Ip:        "10.0.0.1",
+		UserAgent: "Mozilla/5.0",
+		Attrs:     map[string]string{"os": "macOS"},
+	})
+	time.Sleep(2 * time.Millisecond)
+	app, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "app", Ip: "10.0.0.2"})
+
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, mgr.RenewTimeout(ctx, pc, time.Hour))
+
+	devices, err := mgr.ListDevices(ctx, 10001)
+	assert.NoError(t, err)
+	assert.Len(t, devices, 2)
+	assert.Equal(t, pc, devices[0].Value)
+	assert.Equal(t, "10.0.0.1", devices[0].Ip)
+	assert.Equal(t, "Mozilla/5.0", devices[0].UserAgent)
+	assert.Equal(t, "macOS", devices[0].Attrs["os"])
+	// 续期时刷新最近活跃时间
+	assert.Greater(t, devices[0].LastActiveTime, devices[0].CreateTime)
+	assert.GreaterOrEqual(t, devices[1].LastActiveTime, devices[1].CreateTime)
+
+	assert.ErrorIs(t, mgr.LogoutDevice(ctx, 10002, app), satoken.ErrNoToken)
+	assert.NoError(t, mgr.LogoutDevice(ctx, 10001, app))
+	_, err = mgr.GetLoginId(ctx, app)
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+	devices, _ = mgr.ListDevices(ctx, 10001)
+	assert.Len(t, devices, 1)
+
+	devices, err = mgr.ListDevices(ctx, 10002)
+	assert.NoError(t, err)
+	assert.Empty(t, devices)
+}
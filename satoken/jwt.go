@@ -0,0 +1,286 @@
+package satoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/spf13/cast"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// JwtModeStateless 无状态模式，Token 只在本地校验，不使用 TokenStore
+	JwtModeStateless = "stateless"
+	// JwtModeMixed 混合模式，Token 先在本地校验签名和有效期，会话、注销、踢人仍使用 TokenStore，
+	// jwt 的 exp 在签发时确定，AutoRenew 不会延长
+	JwtModeMixed = "mixed"
+)
+
+var (
+	ErrJwtStateless = errors.New("operation not supported in stateless jwt mode")
+	// ErrJwtCustomToken LoginModel.Token 不是 jwt，无法在 jwt 模式下校验
+	ErrJwtCustomToken = errors.New("custom token is not supported in jwt mode")
+
+	errJwtSignature = errors.New("jwt signature is invalid")
+	jwtEncoding     = base64.RawURLEncoding
+)
+
+// JwtAlgorithm jwt signing algorithm
+type JwtAlgorithm interface {
+	// Name the "alg" header value
+	Name() string
+	Sign(data []byte) ([]byte, error)
+	Verify(data, signature []byte) error
+}
+
+// JwtClaims the claims carried by the jwt token
+type JwtClaims struct {
+	Id        string `json:"jti"`
+	LoginId   string `json:"loginId"`
+	LoginType string `json:"loginType"`
+	Device    string `json:"device"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// UseJwt switch the manager to the jwt mode, JwtModeStateless or JwtModeMixed
+func (m *Manager) UseJwt(mode string, alg JwtAlgorithm) {
+	if mode != JwtModeStateless && mode != JwtModeMixed {
+		panic(fmt.Sprintf("unknown jwt mode: %s", mode))
+	}
+	if alg == nil {
+		panic("jwt algorithm cannot be nil")
+	}
+	m.jwtMode = mode
+	m.jwtAlg = alg
+}
+
+// ParseJwt verify the jwt token and return its claims
+func (m *Manager) ParseJwt(tokenValue string) (*JwtClaims, error) {
+	if m.jwtAlg == nil {
+		return nil, fmt.Errorf("jwt mode is not enabled")
+	}
+	parts := strings.Split(tokenValue, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err := decodeJwtSegment(parts[0], &header); err != nil || header.Alg != m.jwtAlg.Name() {
+		return nil, ErrInvalidToken
+	}
+	signature, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err = m.jwtAlg.Verify([]byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims JwtClaims
+	if err = decodeJwtSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.LoginType != m.loginType || claims.LoginId == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.ExpiresAt > 0 && time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenTimeout
+	}
+	return &claims, nil
+}
+
+func (m *Manager) isJwtStateless() bool {
+	return m.jwtMode == JwtModeStateless
+}
+
+// 签发jwt Token
+func (m *Manager) createJwtValue(loginId any, model LoginModel) (string, error) {
+	now := time.Now()
+	claims := JwtClaims{
+		Id:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+		LoginId:   cast.ToString(loginId),
+		LoginType: m.loginType,
+		Device:    model.getDeviceOrDefault(),
+		IssuedAt:  now.Unix(),
+	}
+	if timeout := model.getTimeoutOrDefault(m.getConfigOrGlobal().Timeout); timeout > 0 {
+		claims.ExpiresAt = now.Add(timeout).Unix()
+	}
+	header, err := encodeJwtSegment(jwtHeader{Alg: m.jwtAlg.Name(), Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := encodeJwtSegment(claims)
+	if err != nil {
+		return "", err
+	}
+	signingString := header + "." + payload
+	signature, err := m.jwtAlg.Sign([]byte(signingString))
+	if err != nil {
+		return "", err
+	}
+	return signingString + "." + jwtEncoding.EncodeToString(signature), nil
+}
+
+func encodeJwtSegment(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return jwtEncoding.EncodeToString(data), nil
+}
+
+func decodeJwtSegment(segment string, v any) error {
+	data, err := jwtEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// NewJwtHS256 create the HMAC SHA-256 algorithm
+func NewJwtHS256(secret []byte) JwtAlgorithm {
+	if len(secret) == 0 {
+		panic("jwt secret cannot be empty")
+	}
+	return &jwtHmac{secret: secret}
+}
+
+type jwtHmac struct {
+	secret []byte
+}
+
+func (a *jwtHmac) Name() string {
+	return "HS256"
+}
+
+func (a *jwtHmac) Sign(data []byte) ([]byte, error) {
+	h := hmac.New(sha256.New, a.secret)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+func (a *jwtHmac) Verify(data, signature []byte) error {
+	expected, _ := a.Sign(data)
+	if !hmac.Equal(expected, signature) {
+		return errJwtSignature
+	}
+	return nil
+}
+
+// NewJwtRS256 create the RSA SHA-256 algorithm, it panics if the key is nil
+func NewJwtRS256(key *rsa.PrivateKey) JwtAlgorithm {
+	if key == nil {
+		panic("jwt rsa key cannot be nil")
+	}
+	return &jwtRsa{private: key, public: &key.PublicKey}
+}
+
+// NewJwtRS256Verifier create the RSA SHA-256 algorithm which can only verify tokens,
+// it panics if the key is nil
+func NewJwtRS256Verifier(key *rsa.PublicKey) JwtAlgorithm {
+	if key == nil {
+		panic("jwt rsa key cannot be nil")
+	}
+	return &jwtRsa{public: key}
+}
+
+type jwtRsa struct {
+	private *rsa.PrivateKey
+	public  *rsa.PublicKey
+}
+
+func (a *jwtRsa) Name() string {
+	return "RS256"
+}
+
+func (a *jwtRsa) Sign(data []byte) ([]byte, error) {
+	if a.private == nil {
+		return nil, fmt.Errorf("jwt private key not found")
+	}
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, a.private, crypto.SHA256, digest[:])
+}
+
+func (a *jwtRsa) Verify(data, signature []byte) error {
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(a.public, crypto.SHA256, digest[:], signature)
+}
+
+// NewJwtES256 create the ECDSA P-256 SHA-256 algorithm, it panics if the key is nil or not on P-256
+func NewJwtES256(key *ecdsa.PrivateKey) JwtAlgorithm {
+	if key == nil {
+		panic("jwt ecdsa key cannot be nil")
+	}
+	checkJwtES256Key(&key.PublicKey)
+	return &jwtEcdsa{private: key, public: &key.PublicKey}
+}
+
+// NewJwtES256Verifier create the ECDSA P-256 SHA-256 algorithm which can only verify tokens,
+// it panics if the key is nil or not on P-256
+func NewJwtES256Verifier(key *ecdsa.PublicKey) JwtAlgorithm {
+	if key == nil {
+		panic("jwt ecdsa key cannot be nil")
+	}
+	checkJwtES256Key(key)
+	return &jwtEcdsa{public: key}
+}
+
+func checkJwtES256Key(key *ecdsa.PublicKey) {
+	if key.Curve != elliptic.P256() {
+		panic("es256 requires a P-256 key")
+	}
+}
+
+type jwtEcdsa struct {
+	private *ecdsa.PrivateKey
+	public  *ecdsa.PublicKey
+}
+
+func (a *jwtEcdsa) Name() string {
+	return "ES256"
+}
+
+// Sign jwt 使用定长的 r||s 作为签名
+func (a *jwtEcdsa) Sign(data []byte) ([]byte, error) {
+	if a.private == nil {
+		return nil, fmt.Errorf("jwt private key not found")
+	}
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, a.private, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return signature, nil
+}
+
+func (a *jwtEcdsa) Verify(data, signature []byte) error {
+	if len(signature) != 64 {
+		return errJwtSignature
+	}
+	digest := sha256.Sum256(data)
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(a.public, digest[:], r, s) {
+		return errJwtSignature
+	}
+	return nil
+}
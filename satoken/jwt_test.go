@@ -0,0 +1,129 @@
+package satoken_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManager_JwtStateless(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	algorithms := []satoken.JwtAlgorithm{
+		satoken.NewJwtHS256([]byte("secret")),
+		satoken.NewJwtRS256(rsaKey),
+		satoken.NewJwtES256(ecKey),
+	}
+	for _, alg := range algorithms {
+		// 无状态模式不需要存储
+		mgr := satoken.NewDefaultManager()
+		mgr.UseJwt(satoken.JwtModeStateless, alg)
+
+		token, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "app"})
+		assert.NoError(t, err, alg.Name())
+		loginId, err := mgr.GetLoginId(ctx, token)
+		assert.NoError(t, err, alg.Name())
+		assert.Equal(t, "10001", loginId)
+
+		claims, err := mgr.ParseJwt(token)
+		assert.NoError(t, err)
+		assert.Equal(t, "app", claims.Device)
+		assert.Equal(t, "login", claims.LoginType)
+
+		_, err = mgr.GetLoginId(ctx, tamperJwt(token))
+		assert.ErrorIs(t, err, satoken.ErrInvalidToken, alg.Name())
+		_, err = mgr.GetSession(ctx, token, true)
+		assert.ErrorIs(t, err, satoken.ErrJwtStateless)
+		// 无法注销，Token仍然有效
+		assert.ErrorIs(t, mgr.LogoutByToken(ctx, token), satoken.ErrJwtStateless)
+	}
+
+	verifier := satoken.NewDefaultManager()
+	verifier.UseJwt(satoken.JwtModeStateless, satoken.NewJwtRS256Verifier(&rsaKey.PublicKey))
+	signer := satoken.NewDefaultManager()
+	signer.UseJwt(satoken.JwtModeStateless, satoken.NewJwtRS256(rsaKey))
+	token, _ := signer.Login(ctx, 10001, satoken.LoginModel{})
+	_, err := verifier.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	_, err = verifier.Login(ctx, 10001, satoken.LoginModel{})
+	assert.Error(t, err)
+
+	other := satoken.NewManager("admin")
+	other.UseJwt(satoken.JwtModeStateless, satoken.NewJwtRS256(rsaKey))
+	_, err = other.GetLoginId(ctx, token)
+	assert.ErrorIs(t, err, satoken.ErrInvalidToken)
+}
+
+func TestJwtAlgorithm_InvalidKey(t *testing.T) {
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	assert.Panics(t, func() { satoken.NewJwtRS256(nil) })
+	assert.Panics(t, func() { satoken.NewJwtRS256Verifier(nil) })
+	assert.Panics(t, func() { satoken.NewJwtES256(nil) })
+	assert.Panics(t, func() { satoken.NewJwtES256(p384Key) })
+	assert.Panics(t, func() { satoken.NewJwtES256Verifier(&p384Key.PublicKey) })
+}
+
+func TestManager_JwtExpired(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.Timeout = time.Second
+	mgr := satoken.NewDefaultManager()
+	mgr.SetCfg(cfg)
+	mgr.UseJwt(satoken.JwtModeStateless, satoken.NewJwtHS256([]byte("secret")))
+
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	time.Sleep(1100 * time.Millisecond)
+	_, err := mgr.GetLoginId(ctx, token)
+	assert.ErrorIs(t, err, satoken.ErrTokenTimeout)
+
+	token, _ = mgr.Login(ctx, 10001, satoken.LoginModel{Timeout: -1})
+	claims, err := mgr.ParseJwt(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), claims.ExpiresAt)
+}
+
+func TestManager_JwtMixed(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+	mgr.UseJwt(satoken.JwtModeMixed, satoken.NewJwtHS256([]byte("secret")))
+
+	token, err := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.NoError(t, err)
+	_, err = mgr.ParseJwt(token)
+	assert.NoError(t, err)
+	loginId, err := mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "10001", loginId)
+
+	sess, err := mgr.GetSession(ctx, token, true)
+	assert.NoError(t, err)
+	assert.NotNil(t, sess)
+
+	assert.NoError(t, mgr.KickoutByToken(ctx, token))
+	_, err = mgr.GetLoginId(ctx, token)
+	assert.ErrorIs(t, err, satoken.ErrKickOut)
+
+	_, err = mgr.GetLoginId(ctx, "forged.token.value")
+	assert.ErrorIs(t, err, satoken.ErrInvalidToken)
+
+	// 自定义Token不是 jwt
+	_, err = mgr.Login(ctx, 10001, satoken.LoginModel{Token: "custom"})
+	assert.ErrorIs(t, err, satoken.ErrJwtCustomToken)
+}
+
+// 修改签名中间的一个字符，末尾字符可能只有填充位不同
+func tamperJwt(token string) string {
+	index := len(token) - 10
+	replacement := "A"
+	if token[index] == 'A' {
+		replacement = "B"
+	}
+	return token[:index] + replacement + token[index+1:]
+}
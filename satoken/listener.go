@@ -0,0 +1,68 @@
+package satoken
+
+import (
+	"context"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"time"
+)
+
+// Listener login lifecycle listener, a panic in a listener is recovered and logged
+type Listener interface {
+	// OnLogin fired after the account logged in or a refresh issued a new access token
+	OnLogin(ctx context.Context, loginType string, loginId any, tokenValue string, model LoginModel)
+	// OnLogout fired after the token logged out or was rotated by a refresh
+	OnLogout(ctx context.Context, loginType string, loginId any, tokenValue string)
+	// OnReplaced fired after the token was replaced by a new login
+	OnReplaced(ctx context.Context, loginType string, loginId any, tokenValue string, device string)
+	// OnKickout fired after the token was kicked out
+	OnKickout(ctx context.Context, loginType string, loginId any, tokenValue string)
+	// OnCreateSession fired after the session was created
+	OnCreateSession(ctx context.Context, sessionId string)
+	// OnDeleteSession fired after the session was deleted, sessions never created are not reported
+	OnDeleteSession(ctx context.Context, sessionId string)
+	// OnRenewTimeout fired after the token was renewed
+	OnRenewTimeout(ctx context.Context, loginType string, loginId any, tokenValue string, timeout time.Duration)
+}
+
+// ListenerAdapter listener ignoring all events, embed it to implement only part of the events
+type ListenerAdapter struct{}
+
+func (ListenerAdapter) OnLogin(context.Context, string, any, string, LoginModel) {}
+
+func (ListenerAdapter) OnLogout(context.Context, string, any, string) {}
+
+func (ListenerAdapter) OnReplaced(context.Context, string, any, string, string) {}
+
+func (ListenerAdapter) OnKickout(context.Context, string, any, string) {}
+
+func (ListenerAdapter) OnCreateSession(context.Context, string) {}
+
+func (ListenerAdapter) OnDeleteSession(context.Context, string) {}
+
+func (ListenerAdapter) OnRenewTimeout(context.Context, string, any, string, time.Duration) {}
+
+// AddListener register the listeners, they are notified in the order of registration,
+// listeners should be registered before the manager is used
+func (m *Manager) AddListener(listeners ...Listener) {
+	m.listeners = append(m.listeners, listeners...)
+}
+
+// 依次通知监听器，单个监听器的 panic 不影响其他监听器和登录流程，批量操作中提交后再通知
+func (m *Manager) notify(ctx context.Context, event func(l Listener)) {
+	if state := getBatchState(ctx); state != nil {
+		state.events = append(state.events, func() {
+			m.notify(ctx, event)
+		})
+		return
+	}
+	for _, l := range m.listeners {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					hlog.CtxErrorf(ctx, "satoken: listener panic: %v", r)
+				}
+			}()
+			event(l)
+		}()
+	}
+}
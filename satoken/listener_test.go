@@ -0,0 +1,110 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type recordListener struct {
+	satoken.ListenerAdapter
+	events   []string
+	sessions []string
+}
+
+func (l *recordListener) OnLogin(_ context.Context, _ string, _ any, _ string, _ satoken.LoginModel) {
+	l.events = append(l.events, "login")
+}
+
+func (l *recordListener) OnLogout(_ context.Context, _ string, _ any, _ string) {
+	l.events = append(l.events, "logout")
+}
+
+func (l *recordListener) OnReplaced(_ context.Context, _ string, _ any, _ string, _ string) {
+	l.events = append(l.events, "replaced")
+}
+
+func (l *recordListener) OnKickout(_ context.Context, _ string, _ any, _ string) {
+	l.events = append(l.events, "kickout")
+}
+
+func (l *recordListener) OnRenewTimeout(_ context.Context, _ string, _ any, _ string, _ time.Duration) {
+	l.events = append(l.events, "renew")
+}
+
+func (l *recordListener) OnCreateSession(_ context.Context, sessionId string) {
+	l.sessions = append(l.sessions, "create "+sessionId)
+}
+
+func (l *recordListener) OnDeleteSession(_ context.Context, sessionId string) {
+	l.sessions = append(l.sessions, "delete "+sessionId)
+}
+
+type panicListener struct {
+	satoken.ListenerAdapter
+}
+
+func (panicListener) OnLogin(context.Context, string, any, string, satoken.LoginModel) {
+	panic("listener failed")
+}
+
+func TestManager_Listener(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+	l := &recordListener{}
+	mgr.AddListener(panicListener{}, l)
+
+	first, err := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.NoError(t, err)
+	second, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.NoError(t, mgr.RenewTimeout(ctx, second, time.Hour))
+	assert.NoError(t, mgr.KickoutByToken(ctx, second))
+	third, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.NoError(t, mgr.LogoutByToken(ctx, third))
+	assert.NoError(t, mgr.LogoutByToken(ctx, first))
+
+	assert.Equal(t, []string{"login", "replaced", "login", "renew", "kickout", "login", "logout"}, l.events)
+}
+
+func TestManager_ListenerSession(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+	l := &recordListener{}
+	mgr.AddListener(l)
+
+	// 未创建Token Session时注销只删除账号Session
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.NoError(t, mgr.LogoutByToken(ctx, token))
+	assert.Equal(t, []string{"create satoken:login:session:10001", "delete satoken:login:session:10001"}, l.sessions)
+
+	l.sessions = nil
+	token, _ = mgr.Login(ctx, 10001, satoken.LoginModel{})
+	_, err := mgr.GetSession(ctx, token, true)
+	assert.NoError(t, err)
+	assert.NoError(t, mgr.LogoutByToken(ctx, token))
+	assert.Equal(t, []string{
+		"create satoken:login:session:10001",
+		"create satoken:login:token-session:" + token,
+		"delete satoken:login:token-session:" + token,
+		"delete satoken:login:session:10001",
+	}, l.sessions)
+}
+
+func TestManager_ListenerRefresh(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+	l := &recordListener{}
+	mgr.AddListener(l)
+
+	pair, err := mgr.LoginWithRefresh(ctx, 10001, satoken.LoginModel{})
+	assert.NoError(t, err)
+	_, err = mgr.Refresh(ctx, pair.RefreshToken)
+	assert.NoError(t, err)
+	// 重复使用注销当前的访问Token
+	_, err = mgr.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, satoken.ErrRefreshTokenReused)
+
+	assert.Equal(t, []string{"login", "logout", "login", "logout"}, l.events)
+}
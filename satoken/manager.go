@@ -3,12 +3,20 @@ package satoken
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/myhaiting/go-fly-lib/bizerr"
+	"sync"
+	"time"
 )
 
 var (
 	ErrObjectNotExist = errors.New("object not exist")
 	ErrTokenNotExist  = errors.New("token not exist")
+	ErrScanNotSupport = errors.New("token store does not support scan")
+	// ErrSearchTooManyKeys too many keys match the keyword of a search
+	ErrSearchTooManyKeys = errors.New("too many keys to search, narrow down the keyword")
+	// ErrSessionConflict the session was saved by others since it was loaded
+	ErrSessionConflict = errors.New("session version conflict")
 
 	ErrNoToken      = bizerr.New(10000, "satoken.token.notExist")
 	ErrInvalidToken = bizerr.New(10001, "satoken.token.invalid")
@@ -17,6 +25,16 @@ var (
 	ErrKickOut      = bizerr.New(10004, "satoken.token.beKickOut")
 	ErrTokenFreeze  = bizerr.New(10005, "satoken.token.freeze")
 	ErrNoPrefix     = bizerr.New(10006, "satoken.token.noPrefix")
+
+	ErrTokenActiveTimeout = bizerr.New(10007, "satoken.token.activeTimeout")
+	ErrNotSafe            = bizerr.New(10008, "satoken.safe.notSafe")
+	ErrNoPermission       = bizerr.New(10009, "satoken.permission.notHave")
+	ErrNoRole             = bizerr.New(10010, "satoken.role.notHave")
+
+	ErrRefreshTokenInvalid = bizerr.New(10011, "satoken.refresh.invalid")
+	ErrRefreshTokenReused  = bizerr.New(10012, "satoken.refresh.reused")
+	ErrTempTokenInvalid    = bizerr.New(10013, "satoken.temp.invalid")
+	ErrLoginLocked         = bizerr.New(10014, "satoken.login.locked")
 )
 
 const (
@@ -41,9 +59,18 @@ func NewManager(loginType string) *Manager {
 
 // Manager provide authorization management
 type Manager struct {
-	cfg        *Config
-	tokenStore TokenStore
-	loginType  string
+	cfg            *Config
+	tokenStore     TokenStore
+	loginType      string
+	tokenGenerator TokenGenerator
+	jwtMode        string
+	jwtAlg         JwtAlgorithm
+
+	permissionProvider PermissionProvider
+	listeners          []Listener
+	refreshMu          sync.Mutex
+	tempMu             sync.Mutex
+	attemptMu          sync.Mutex
 }
 
 // SetCfg set the authorization code grant token config
@@ -64,9 +91,24 @@ func (m *Manager) MustTokenStorage(store TokenStore, err error) {
 	m.tokenStore = store
 }
 
+// SetTokenGenerator set the custom token generator, it takes precedence over Config.TokenStyle
+func (m *Manager) SetTokenGenerator(f TokenGenerator) {
+	m.tokenGenerator = f
+}
+
 // GetLoginId getToken
 func (m *Manager) GetLoginId(ctx context.Context, token string) (string, error) {
-	loginId, err := m.tokenStore.Get(ctx, m.splicingKeyTokenValue(token))
+	// jwt 本地校验
+	if m.jwtAlg != nil {
+		claims, err := m.ParseJwt(token)
+		if err != nil {
+			return "", bizerr.WrapBizError(ctx, err)
+		}
+		if m.isJwtStateless() {
+			return claims.LoginId, nil
+		}
+	}
+	loginId, err := m.getStore(ctx).Get(ctx, m.splicingKeyTokenValue(ctx, token))
 	if err != nil {
 		if errors.Is(err, ErrTokenNotExist) {
 			return "", bizerr.WrapBizError(ctx, ErrNoToken)
@@ -76,30 +118,100 @@ func (m *Manager) GetLoginId(ctx context.Context, token string) (string, error)
 	if err = m.isValidLoginId(loginId); err != nil {
 		return "", bizerr.WrapBizError(ctx, err)
 	}
+	// 账号封禁校验
+	if m.getConfigOrGlobal().IsCheckDisable {
+		if err = m.CheckDisable(ctx, loginId, DefaultDisableService); err != nil {
+			return "", bizerr.WrapBizError(ctx, err)
+		}
+	}
+	// 活跃超时校验
+	if err = m.checkAndTouchActive(ctx, token); err != nil {
+		return "", bizerr.WrapBizError(ctx, err)
+	}
+	// 自动续期
+	if m.getConfigOrGlobal().AutoRenew {
+		if err = m.renewTimeoutIfNecessary(ctx, token, loginId); err != nil {
+			return "", bizerr.WrapBizError(ctx, err)
+		}
+	}
 	return loginId, nil
 }
 
+// RenewTimeout renew the token, its sessions and last active record to the given timeout
+func (m *Manager) RenewTimeout(ctx context.Context, tokenValue string, timeout time.Duration) error {
+	if m.isJwtStateless() {
+		return ErrJwtStateless
+	}
+	if timeout <= 0 {
+		return fmt.Errorf("renew timeout must be positive")
+	}
+	loginId, err := m.getStore(ctx).Get(ctx, m.splicingKeyTokenValue(ctx, tokenValue))
+	if err != nil {
+		return err
+	}
+	if err = m.isValidLoginId(loginId); err != nil {
+		return err
+	}
+	return m.renewTimeout(ctx, tokenValue, loginId, timeout)
+}
+
 // Login login
 func (m *Manager) Login(ctx context.Context, loginId any, model LoginModel) (string, error) {
-	tokenValue, err := m.createLoginSession(ctx, loginId, model)
+	if m.jwtAlg != nil && model.Token != "" {
+		return "", ErrJwtCustomToken
+	}
+	// 无状态jwt不使用存储
+	if m.isJwtStateless() {
+		tokenValue, err := m.createJwtValue(loginId, model)
+		if err != nil {
+			return "", err
+		}
+		m.notify(ctx, func(l Listener) {
+			l.OnLogin(ctx, m.loginType, loginId, tokenValue, model)
+		})
+		return tokenValue, nil
+	}
+	// 账号封禁校验
+	if err := m.CheckDisable(ctx, loginId, DefaultDisableService); err != nil {
+		return "", bizerr.WrapBizError(ctx, err)
+	}
+	var tokenValue string
+	err := m.runBatch(ctx, func(ctx context.Context) (err error) {
+		tokenValue, err = m.createLoginSession(ctx, loginId, model)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
 	if err = m.setTokenValue(ctx, tokenValue); err != nil {
 		return "", err
 	}
+	m.notify(ctx, func(l Listener) {
+		l.OnLogin(ctx, m.loginType, loginId, tokenValue, model)
+	})
 	return tokenValue, nil
 }
 
 // LogoutByLoginId logout
 func (m *Manager) LogoutByLoginId(ctx context.Context, loginId any, device string) error {
+	if m.isJwtStateless() {
+		return ErrJwtStateless
+	}
+	return m.runBatch(ctx, func(ctx context.Context) error {
+		return m.logoutByLoginId(ctx, loginId, device)
+	})
+}
+
+func (m *Manager) logoutByLoginId(ctx context.Context, loginId any, device string) error {
 	sess, err := m.getSessionByLoginId(ctx, loginId, false)
 	if err != nil {
 		return err
 	}
+	removed := make([]string, 0)
 	for _, item := range sess.getTokenSignListByDevice(device) {
 		// 删除Token
 		sess.removeTokenSign(item.Value)
+		removed = append(removed, item.Value)
 		// 删除TokenMapping
 		if err = m.deleteTokenToIdMapping(ctx, item.Value); err != nil {
 			return err
@@ -108,28 +220,42 @@ func (m *Manager) LogoutByLoginId(ctx context.Context, loginId any, device strin
 		if err = m.deleteTokenSession(ctx, item.Value); err != nil {
 			return err
 		}
-	}
-	// 如果没有Token则注销会话
-	if len(sess.TokenSignList) == 0 {
-		// 删除Session
-		if err = m.deleteSession(ctx, sess.Id); err != nil {
+		// 删除最近活跃时间
+		if err = m.deleteLastActive(ctx, item.Value); err != nil {
 			return err
 		}
-	} else {
-		// 保存Session
-		if err = sess.Save(); err != nil {
+		// 删除刷新Token
+		if err = m.deleteRefreshFamily(ctx, item.RefreshFamily); err != nil {
 			return err
 		}
+		m.notify(ctx, func(l Listener) {
+			l.OnLogout(ctx, m.loginType, loginId, item.Value)
+		})
 	}
-	return nil
+	// 如果没有Token则注销会话
+	return m.saveOrDeleteSession(ctx, sess, removed...)
 }
 
 // LogoutByToken logout
 func (m *Manager) LogoutByToken(ctx context.Context, tokenValue string) error {
+	// 无状态jwt无法使Token失效，由客户端丢弃Token
+	if m.isJwtStateless() {
+		return ErrJwtStateless
+	}
+	return m.runBatch(ctx, func(ctx context.Context) error {
+		return m.logoutByToken(ctx, tokenValue)
+	})
+}
+
+func (m *Manager) logoutByToken(ctx context.Context, tokenValue string) error {
 	// 删除Token Session
 	if err := m.deleteTokenSession(ctx, tokenValue); err != nil {
 		return err
 	}
+	// 删除最近活跃时间
+	if err := m.deleteLastActive(ctx, tokenValue); err != nil {
+		return err
+	}
 	// 获取LoginId
 	loginId := m.getLoginIdNotHandle(ctx, tokenValue)
 	if loginId != "" {
@@ -141,6 +267,9 @@ func (m *Manager) LogoutByToken(ctx context.Context, tokenValue string) error {
 	if err := m.isValidLoginId(loginId); err != nil {
 		return nil
 	}
+	m.notify(ctx, func(l Listener) {
+		l.OnLogout(ctx, m.loginType, loginId, tokenValue)
+	})
 	// 获取登录会话
 	sess, err := m.getSessionByLoginId(ctx, loginId, false)
 	if err != nil {
@@ -149,22 +278,97 @@ func (m *Manager) LogoutByToken(ctx context.Context, tokenValue string) error {
 		}
 		return err
 	}
+	// 删除刷新Token
+	if err = m.deleteRefreshFamilyOfToken(ctx, sess, tokenValue); err != nil {
+		return err
+	}
 	sess.removeTokenSign(tokenValue)
 	// 如果没有Token则注销会话
-	if len(sess.TokenSignList) == 0 {
-		if err = m.deleteSession(ctx, sess.Id); err != nil {
+	return m.saveOrDeleteSession(ctx, sess, tokenValue)
+}
+
+// KickoutByLoginId kick the account offline, its tokens are kept as KICK_OUT until they expire
+func (m *Manager) KickoutByLoginId(ctx context.Context, loginId any, device string) error {
+	if m.isJwtStateless() {
+		return ErrJwtStateless
+	}
+	return m.runBatch(ctx, func(ctx context.Context) error {
+		return m.kickoutByLoginId(ctx, loginId, device)
+	})
+}
+
+func (m *Manager) kickoutByLoginId(ctx context.Context, loginId any, device string) error {
+	sess, err := m.getSessionByLoginId(ctx, loginId, false)
+	if err != nil {
+		return err
+	}
+	removed := make([]string, 0)
+	for _, item := range sess.getTokenSignListByDevice(device) {
+		// 删除Token
+		sess.removeTokenSign(item.Value)
+		removed = append(removed, item.Value)
+		// 标记为被踢下线
+		if err = m.kickoutToken(ctx, item.Value); err != nil {
 			return err
 		}
-	} else {
-		if err = sess.Save(); err != nil {
+		// 删除刷新Token
+		if err = m.deleteRefreshFamily(ctx, item.RefreshFamily); err != nil {
 			return err
 		}
+		m.notify(ctx, func(l Listener) {
+			l.OnKickout(ctx, m.loginType, loginId, item.Value)
+		})
+	}
+	// 如果没有Token则注销会话
+	return m.saveOrDeleteSession(ctx, sess, removed...)
+}
+
+// KickoutByToken kick the token offline, it is kept as KICK_OUT until it expires
+func (m *Manager) KickoutByToken(ctx context.Context, tokenValue string) error {
+	if m.isJwtStateless() {
+		return ErrJwtStateless
 	}
-	return nil
+	return m.runBatch(ctx, func(ctx context.Context) error {
+		return m.kickoutByToken(ctx, tokenValue)
+	})
+}
+
+func (m *Manager) kickoutByToken(ctx context.Context, tokenValue string) error {
+	// 获取LoginId
+	loginId := m.getLoginIdNotHandle(ctx, tokenValue)
+	// 判断Id是否可用
+	if err := m.isValidLoginId(loginId); err != nil {
+		return nil
+	}
+	// 标记为被踢下线
+	if err := m.kickoutToken(ctx, tokenValue); err != nil {
+		return err
+	}
+	m.notify(ctx, func(l Listener) {
+		l.OnKickout(ctx, m.loginType, loginId, tokenValue)
+	})
+	// 获取登录会话
+	sess, err := m.getSessionByLoginId(ctx, loginId, false)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return nil
+		}
+		return err
+	}
+	// 删除刷新Token
+	if err = m.deleteRefreshFamilyOfToken(ctx, sess, tokenValue); err != nil {
+		return err
+	}
+	sess.removeTokenSign(tokenValue)
+	// 如果没有Token则注销会话
+	return m.saveOrDeleteSession(ctx, sess, tokenValue)
 }
 
 // GetSession session
 func (m *Manager) GetSession(ctx context.Context, tokenValue string, isCreate bool) (*Session, error) {
+	if m.isJwtStateless() {
+		return nil, ErrJwtStateless
+	}
 	_, err := m.GetLoginId(ctx, tokenValue)
 	if err != nil {
 		return nil, err
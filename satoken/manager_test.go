@@ -0,0 +1,465 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/myhaiting/go-fly-lib/satoken/store"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T, cfg *satoken.Config) *satoken.Manager {
+	mgr := satoken.NewDefaultManager()
+	if cfg != nil {
+		mgr.SetCfg(cfg)
+	}
+	s := store.NewMemoryStore()
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	mgr.MapTokenStorage(s)
+	return mgr
+}
+
+// 创建两个共享同一存储的Manager，模拟多个实例
+func newTestManagers(t *testing.T, cfg *satoken.Config) []*satoken.Manager {
+	s := store.NewMemoryStore()
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	mgrs := []*satoken.Manager{satoken.NewDefaultManager(), satoken.NewDefaultManager()}
+	for _, mgr := range mgrs {
+		if cfg != nil {
+			mgr.SetCfg(cfg)
+		}
+		mgr.MapTokenStorage(s)
+	}
+	return mgrs
+}
+
+// 轮流使用各个实例并发执行 n 次 fn，返回成功的次数
+func runConcurrent(mgrs []*satoken.Manager, n int, fn func(mgr *satoken.Manager) error) int {
+	var succeeded atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(mgr *satoken.Manager) {
+			defer wg.Done()
+			if fn(mgr) == nil {
+				succeeded.Add(1)
+			}
+		}(mgrs[i%len(mgrs)])
+	}
+	wg.Wait()
+	return int(succeeded.Load())
+}
+
+func TestManager_Login(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+
+	token, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	loginId, err := mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "10001", loginId)
+
+	sess, err := mgr.GetSession(ctx, token, true)
+	assert.NoError(t, err)
+	sess.Set("name", "demo")
+	assert.NoError(t, sess.Save())
+	sess, err = mgr.GetSession(ctx, token, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "demo", sess.Get("name"))
+
+	_, err = mgr.GetLoginId(ctx, "not-exist")
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+}
+
+func TestManager_Replaced(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+
+	first, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	assert.NoError(t, err)
+	second, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	assert.NoError(t, err)
+
+	_, err = mgr.GetLoginId(ctx, first)
+	assert.ErrorIs(t, err, satoken.ErrBeReplaced)
+	_, err = mgr.GetLoginId(ctx, second)
+	assert.NoError(t, err)
+}
+
+func TestManager_Logout(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := newTestManager(t, cfg)
+
+	pc, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	app, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "app"})
+
+	assert.NoError(t, mgr.LogoutByToken(ctx, pc))
+	_, err := mgr.GetLoginId(ctx, pc)
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+	_, err = mgr.GetLoginId(ctx, app)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mgr.LogoutByLoginId(ctx, 10001, ""))
+	_, err = mgr.GetLoginId(ctx, app)
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+}
+
+func TestManager_ActiveTimeout(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	cfg.ActiveTimeout = 300 * time.Millisecond
+	mgr := newTestManager(t, cfg)
+
+	idle, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	active, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "app"})
+	unlimited, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "web", ActiveTimeout: -1})
+
+	// 请求间隔远小于活跃超时，调度延迟不影响结果
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		_, err := mgr.GetLoginId(ctx, active)
+		assert.NoError(t, err)
+	}
+	_, err := mgr.GetLoginId(ctx, idle)
+	assert.ErrorIs(t, err, satoken.ErrTokenActiveTimeout)
+	_, err = mgr.GetLoginId(ctx, unlimited)
+	assert.NoError(t, err)
+}
+
+func TestManager_ActiveTimeoutWithoutRecord(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	mgr := newTestManager(t, cfg)
+
+	// 关闭活跃超时时登录，不写入最近活跃时间
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	cfg.ActiveTimeout = 300 * time.Millisecond
+	_, err := mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+
+	time.Sleep(400 * time.Millisecond)
+	_, err = mgr.GetLoginId(ctx, token)
+	assert.ErrorIs(t, err, satoken.ErrTokenActiveTimeout)
+}
+
+func TestManager_DynamicActiveTimeout(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := newTestManager(t, cfg)
+
+	// 未开启动态活跃超时，登录时指定的活跃超时不生效
+	ignored, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc", ActiveTimeout: 1})
+	cfg.DynamicActiveTimeout = true
+	dynamic, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "app", ActiveTimeout: 1})
+
+	time.Sleep(1500 * time.Millisecond)
+	_, err := mgr.GetLoginId(ctx, ignored)
+	assert.NoError(t, err)
+	_, err = mgr.GetLoginId(ctx, dynamic)
+	assert.ErrorIs(t, err, satoken.ErrTokenActiveTimeout)
+}
+
+func TestManager_AutoRenew(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.Timeout = 500 * time.Millisecond
+	cfg.AutoRenew = true
+	// 每次请求都续期，请求间隔远小于有效期，调度延迟不影响结果
+	cfg.RenewThreshold = 450 * time.Millisecond
+	mgr := newTestManager(t, cfg)
+
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	for i := 0; i < 4; i++ {
+		time.Sleep(150 * time.Millisecond)
+		_, err := mgr.GetLoginId(ctx, token)
+		assert.NoError(t, err)
+	}
+	time.Sleep(600 * time.Millisecond)
+	_, err := mgr.GetLoginId(ctx, token)
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+}
+
+func TestManager_LoginTimeout(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := satoken.NewDefaultManager()
+	mgr.SetCfg(cfg)
+	s := store.NewMemoryStore()
+	defer s.Close()
+	mgr.MapTokenStorage(s)
+
+	normal, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	ttl, _ := s.GetTimeout(ctx, "satoken:login:token:"+normal)
+	assert.True(t, ttl > 29*time.Minute && ttl <= 30*time.Minute)
+	ttl, _ = s.GetObjTimeout(ctx, "satoken:login:session:10001")
+	assert.True(t, ttl > 29*time.Minute && ttl <= 30*time.Minute)
+
+	remember, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "app", Timeout: 30 * 24 * 3600})
+	ttl, _ = s.GetTimeout(ctx, "satoken:login:token:"+remember)
+	assert.True(t, ttl > 29*24*time.Hour)
+	ttl, _ = s.GetObjTimeout(ctx, "satoken:login:session:10001")
+	assert.True(t, ttl > 29*24*time.Hour)
+
+	forever, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "web", Timeout: -1})
+	ttl, _ = s.GetTimeout(ctx, "satoken:login:token:"+forever)
+	assert.Equal(t, satoken.NeverExpire, ttl)
+	ttl, _ = s.GetObjTimeout(ctx, "satoken:login:session:10001")
+	assert.Equal(t, satoken.NeverExpire, ttl)
+
+	sess, err := mgr.GetSession(ctx, forever, true)
+	assert.NoError(t, err)
+	ttl, _ = s.GetObjTimeout(ctx, sess.Id)
+	assert.Equal(t, satoken.NeverExpire, ttl)
+}
+
+func TestManager_MaxLoginCount(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	cfg.MaxLoginCount = 2
+	mgr := newTestManager(t, cfg)
+
+	tokens := make([]string, 0)
+	for _, device := range []string{"pc", "app", "web"} {
+		token, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: device})
+		assert.NoError(t, err)
+		tokens = append(tokens, token)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	_, err := mgr.GetLoginId(ctx, tokens[0])
+	assert.ErrorIs(t, err, satoken.ErrBeReplaced)
+	for _, token := range tokens[1:] {
+		_, err = mgr.GetLoginId(ctx, token)
+		assert.NoError(t, err)
+	}
+}
+
+func TestManager_Kickout(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := newTestManager(t, cfg)
+
+	pc, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	app, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "app"})
+	web, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "web"})
+
+	assert.NoError(t, mgr.KickoutByToken(ctx, pc))
+	_, err := mgr.GetLoginId(ctx, pc)
+	assert.ErrorIs(t, err, satoken.ErrKickOut)
+	_, err = mgr.GetLoginId(ctx, app)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mgr.KickoutByLoginId(ctx, 10001, "app"))
+	_, err = mgr.GetLoginId(ctx, app)
+	assert.ErrorIs(t, err, satoken.ErrKickOut)
+	_, err = mgr.GetLoginId(ctx, web)
+	assert.NoError(t, err)
+
+	// 被踢下线后注销，Token不再存在
+	assert.NoError(t, mgr.LogoutByToken(ctx, pc))
+	_, err = mgr.GetLoginId(ctx, pc)
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+}
+
+func TestManager_Disable(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsCheckDisable = true
+	mgr := newTestManager(t, cfg)
+
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+
+	assert.NoError(t, mgr.Disable(ctx, 10001, "comment", 2, time.Hour))
+	disabled, _ := mgr.IsDisabled(ctx, 10001, "comment")
+	assert.True(t, disabled)
+	disabled, _ = mgr.IsDisabledLevel(ctx, 10001, "comment", 3)
+	assert.False(t, disabled)
+	assert.ErrorIs(t, mgr.CheckDisable(ctx, 10001, "comment"), satoken.ErrTokenFreeze)
+	disableTime, _ := mgr.GetDisableTime(ctx, 10001, "comment")
+	assert.True(t, disableTime > 59*time.Minute)
+	// 其他服务不受影响
+	_, err := mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mgr.Disable(ctx, 10001, "", satoken.DefaultDisableLevel, satoken.NeverExpire))
+	disableTime, _ = mgr.GetDisableTime(ctx, 10001, "")
+	assert.Equal(t, satoken.NeverExpire, disableTime)
+	_, err = mgr.GetLoginId(ctx, token)
+	assert.ErrorIs(t, err, satoken.ErrTokenFreeze)
+	_, err = mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.ErrorIs(t, err, satoken.ErrTokenFreeze)
+
+	assert.NoError(t, mgr.Untie(ctx, 10001, "", "comment"))
+	_, err = mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	disableTime, _ = mgr.GetDisableTime(ctx, 10001, "comment")
+	assert.Equal(t, time.Duration(0), disableTime)
+
+	// 未开启请求时校验，只是无法登录
+	cfg.IsCheckDisable = false
+	assert.NoError(t, mgr.Disable(ctx, 10001, "", satoken.DefaultDisableLevel, time.Hour))
+	_, err = mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	_, err = mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.ErrorIs(t, err, satoken.ErrTokenFreeze)
+}
+
+func TestManager_Safe(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.ErrorIs(t, mgr.CheckSafe(ctx, token), satoken.ErrNotSafe)
+
+	assert.NoError(t, mgr.OpenSafe(ctx, token, "", 50*time.Millisecond))
+	assert.NoError(t, mgr.CheckSafe(ctx, token))
+	assert.ErrorIs(t, mgr.CheckSafe(ctx, token, "payment"), satoken.ErrNotSafe)
+	safeTime, _ := mgr.GetSafeTime(ctx, token, "")
+	assert.True(t, safeTime > 0)
+
+	time.Sleep(60 * time.Millisecond)
+	safe, _ := mgr.IsSafe(ctx, token, "")
+	assert.False(t, safe)
+
+	assert.NoError(t, mgr.OpenSafe(ctx, token, "payment", time.Minute))
+	assert.NoError(t, mgr.CloseSafe(ctx, token, "payment"))
+	assert.ErrorIs(t, mgr.CheckSafe(ctx, token, "payment"), satoken.ErrNotSafe)
+
+	assert.Error(t, mgr.OpenSafe(ctx, "not-exist", "", time.Minute))
+}
+
+func TestManager_FileStoreRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "satoken.log")
+	s, err := store.NewFileStore(path)
+	assert.NoError(t, err)
+	mgr := satoken.NewDefaultManager()
+	mgr.MapTokenStorage(s)
+	token, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Close())
+
+	// 重启后登录状态仍然有效
+	s, err = store.NewFileStore(path)
+	assert.NoError(t, err)
+	defer s.Close()
+	mgr = satoken.NewDefaultManager()
+	mgr.MapTokenStorage(s)
+	loginId, err := mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "10001", loginId)
+	assert.NoError(t, mgr.LogoutByToken(ctx, token))
+}
+
+type testNamespaceKey struct{}
+
+func TestManager_KeyNamespace(t *testing.T) {
+	cfg := satoken.NewDefaultConfig()
+	cfg.KeyPrefix = "app"
+	cfg.KeyNamespace = func(ctx context.Context) string {
+		namespace, _ := ctx.Value(testNamespaceKey{}).(string)
+		return namespace
+	}
+	mgr := satoken.NewDefaultManager()
+	mgr.SetCfg(cfg)
+	s := store.NewMemoryStore()
+	defer s.Close()
+	mgr.MapTokenStorage(s)
+
+	tenantA := context.WithValue(context.Background(), testNamespaceKey{}, "a")
+	tenantB := context.WithValue(context.Background(), testNamespaceKey{}, "b")
+	token, err := mgr.Login(tenantA, 10001, satoken.LoginModel{})
+	assert.NoError(t, err)
+	val, _ := s.Get(tenantA, "app:a:satoken:login:token:"+token)
+	assert.Equal(t, "10001", val)
+
+	// 其他租户不可见
+	_, err = mgr.GetLoginId(tenantB, token)
+	assert.Error(t, err)
+	loginId, err := mgr.GetLoginId(tenantA, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "10001", loginId)
+	assert.NoError(t, mgr.Disable(tenantB, 10001, "", satoken.DefaultDisableLevel, time.Minute))
+	assert.NoError(t, mgr.CheckDisable(tenantA, 10001))
+
+	// 命名空间中的分隔符被转义
+	forged := context.WithValue(context.Background(), testNamespaceKey{}, "a:satoken")
+	_, err = mgr.Login(forged, 10002, satoken.LoginModel{})
+	assert.NoError(t, err)
+	ids, err := mgr.SearchSessions(tenantA, "", 0, -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10001"}, ids)
+
+	assert.True(t, mgr.IsHotPathKey("app:a:satoken:login:last-active:"+token))
+	assert.True(t, mgr.IsHotPathKey("satoken:login:login-failure:account:alice"))
+	assert.False(t, mgr.IsHotPathKey("app:a:satoken:login:token:"+token))
+	assert.False(t, mgr.IsHotPathKey("app:a:satoken:admin:last-active:"+token))
+}
+
+// 统计写入次数的存储
+type countingStore struct {
+	*store.MemoryStore
+	updates int
+}
+
+func (s *countingStore) Update(ctx context.Context, key string, val string) error {
+	s.updates++
+	return s.MemoryStore.Update(ctx, key, val)
+}
+
+func TestManager_ActiveTouchThrottled(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.ActiveTimeout = time.Minute
+	mgr := satoken.NewDefaultManager()
+	mgr.SetCfg(cfg)
+	s := &countingStore{MemoryStore: store.NewMemoryStore()}
+	defer s.Close()
+	mgr.MapTokenStorage(s)
+
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	for i := 0; i < 10; i++ {
+		_, err := mgr.GetLoginId(ctx, token)
+		assert.NoError(t, err)
+	}
+	// 写入间隔内不刷新最近活跃时间
+	assert.Equal(t, 0, s.updates)
+}
+
+func TestManager_AutoRenewLoginTimeout(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.AutoRenew = true
+	mgr := satoken.NewDefaultManager()
+	mgr.SetCfg(cfg)
+	s := store.NewMemoryStore()
+	defer s.Close()
+	mgr.MapTokenStorage(s)
+
+	// 登录有效期远大于全局有效期，阈值按登录有效期计算
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{Timeout: 30 * 24 * 3600})
+	key := "satoken:login:token:" + token
+	assert.NoError(t, s.UpdateTimeout(ctx, key, 10*24*time.Hour))
+	_, err := mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	ttl, _ := s.GetTimeout(ctx, key)
+	assert.True(t, ttl > 29*24*time.Hour)
+}
@@ -0,0 +1,140 @@
+package satoken
+
+import (
+	"context"
+	"errors"
+	"github.com/myhaiting/go-fly-lib/antpath"
+)
+
+// 权限码使用 ":" 分隔，支持 user:* order:**:read 形式的通配符
+var permissionMatcher = antpath.NewS(":")
+
+var ErrPermissionProviderNotFound = errors.New("permission provider not found")
+
+// PermissionProvider provide the permissions and roles of the account
+type PermissionProvider interface {
+	GetPermissions(ctx context.Context, loginId any, loginType string) ([]string, error)
+	GetRoles(ctx context.Context, loginId any, loginType string) ([]string, error)
+}
+
+// SetPermissionProvider set the permission provider of the manager
+func (m *Manager) SetPermissionProvider(p PermissionProvider) {
+	m.permissionProvider = p
+}
+
+// HasPermission whether the account has the permission
+func (m *Manager) HasPermission(ctx context.Context, loginId any, permission string) (bool, error) {
+	permissions, err := m.getPermissions(ctx, loginId)
+	if err != nil {
+		return false, err
+	}
+	return matchAny(permissions, permission), nil
+}
+
+// HasRole whether the account has the role
+func (m *Manager) HasRole(ctx context.Context, loginId any, role string) (bool, error) {
+	roles, err := m.getRoles(ctx, loginId)
+	if err != nil {
+		return false, err
+	}
+	return matchAny(roles, role), nil
+}
+
+// CheckPermission return ErrNoPermission unless the account has the permission
+func (m *Manager) CheckPermission(ctx context.Context, loginId any, permission string) error {
+	return m.CheckPermissionAnd(ctx, loginId, permission)
+}
+
+// CheckPermissionAnd return ErrNoPermission unless the account has all the permissions
+func (m *Manager) CheckPermissionAnd(ctx context.Context, loginId any, permissions ...string) error {
+	owned, err := m.getPermissions(ctx, loginId)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !matchAny(owned, permission) {
+			return ErrNoPermission
+		}
+	}
+	return nil
+}
+
+// CheckPermissionOr return ErrNoPermission unless the account has any of the permissions
+func (m *Manager) CheckPermissionOr(ctx context.Context, loginId any, permissions ...string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	owned, err := m.getPermissions(ctx, loginId)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if matchAny(owned, permission) {
+			return nil
+		}
+	}
+	return ErrNoPermission
+}
+
+// CheckRole return ErrNoRole unless the account has the role
+func (m *Manager) CheckRole(ctx context.Context, loginId any, role string) error {
+	return m.CheckRoleAnd(ctx, loginId, role)
+}
+
+// CheckRoleAnd return ErrNoRole unless the account has all the roles
+func (m *Manager) CheckRoleAnd(ctx context.Context, loginId any, roles ...string) error {
+	owned, err := m.getRoles(ctx, loginId)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if !matchAny(owned, role) {
+			return ErrNoRole
+		}
+	}
+	return nil
+}
+
+// CheckRoleOr return ErrNoRole unless the account has any of the roles
+func (m *Manager) CheckRoleOr(ctx context.Context, loginId any, roles ...string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	owned, err := m.getRoles(ctx, loginId)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if matchAny(owned, role) {
+			return nil
+		}
+	}
+	return ErrNoRole
+}
+
+func (m *Manager) getPermissions(ctx context.Context, loginId any) ([]string, error) {
+	if m.permissionProvider == nil {
+		return nil, ErrPermissionProviderNotFound
+	}
+	return m.permissionProvider.GetPermissions(ctx, loginId, m.loginType)
+}
+
+func (m *Manager) getRoles(ctx context.Context, loginId any) ([]string, error) {
+	if m.permissionProvider == nil {
+		return nil, ErrPermissionProviderNotFound
+	}
+	return m.permissionProvider.GetRoles(ctx, loginId, m.loginType)
+}
+
+// 拥有的权限码中是否有与 value 匹配的，"*" 匹配所有
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == value {
+			return true
+		}
+		if permissionMatcher.IsPattern(pattern) && permissionMatcher.Match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testPermissionProvider struct {
+	permissions map[string][]string
+	roles       map[string][]string
+}
+
+func (p *testPermissionProvider) GetPermissions(_ context.Context, loginId any, _ string) ([]string, error) {
+	return p.permissions[loginId.(string)], nil
+}
+
+func (p *testPermissionProvider) GetRoles(_ context.Context, loginId any, _ string) ([]string, error) {
+	return p.roles[loginId.(string)], nil
+}
+
+func TestManager_Permission(t *testing.T) {
+	ctx := context.Background()
+	mgr := satoken.NewDefaultManager()
+	assert.ErrorIs(t, mgr.CheckPermission(ctx, "1", "user:add"), satoken.ErrPermissionProviderNotFound)
+
+	mgr.SetPermissionProvider(&testPermissionProvider{
+		permissions: map[string][]string{
+			"1": {"user:*", "order:**:read", "goods:list"},
+			"2": {"*"},
+		},
+		roles: map[string][]string{
+			"1": {"editor"},
+			"2": {"admin", "editor"},
+		},
+	})
+
+	ok, err := mgr.HasPermission(ctx, "1", "user:add")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, _ = mgr.HasPermission(ctx, "1", "user:add:batch")
+	assert.False(t, ok)
+	ok, _ = mgr.HasPermission(ctx, "1", "order:2024:10:read")
+	assert.True(t, ok)
+	ok, _ = mgr.HasPermission(ctx, "1", "order:2024:write")
+	assert.False(t, ok)
+	ok, _ = mgr.HasPermission(ctx, "2", "anything:at:all")
+	assert.True(t, ok)
+
+	assert.NoError(t, mgr.CheckPermissionAnd(ctx, "1", "user:add", "goods:list"))
+	assert.ErrorIs(t, mgr.CheckPermissionAnd(ctx, "1", "user:add", "goods:add"), satoken.ErrNoPermission)
+	assert.NoError(t, mgr.CheckPermissionOr(ctx, "1", "goods:add", "goods:list"))
+	assert.ErrorIs(t, mgr.CheckPermissionOr(ctx, "1", "goods:add", "goods:del"), satoken.ErrNoPermission)
+
+	ok, _ = mgr.HasRole(ctx, "2", "admin")
+	assert.True(t, ok)
+	assert.ErrorIs(t, mgr.CheckRole(ctx, "1", "admin"), satoken.ErrNoRole)
+	assert.NoError(t, mgr.CheckRoleOr(ctx, "1", "admin", "editor"))
+	assert.ErrorIs(t, mgr.CheckRoleAnd(ctx, "1", "admin", "editor"), satoken.ErrNoRole)
+}
@@ -0,0 +1,240 @@
+package satoken
+
+import (
+	"context"
+	"errors"
+	"github.com/myhaiting/go-fly-lib/bizerr"
+	"github.com/spf13/cast"
+	"time"
+)
+
+// TokenPair access token and its refresh token
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	// AccessTimeout 访问Token有效期，NeverExpire 表示永不过期
+	AccessTimeout time.Duration `json:"accessTimeout"`
+	// RefreshTimeout 刷新Token有效期，NeverExpire 表示永不过期
+	RefreshTimeout time.Duration `json:"refreshTimeout"`
+}
+
+// 刷新Token记录，轮换后保留至过期，用于识别重复使用
+type refreshRecord struct {
+	LoginId     string     `json:"loginId"`
+	Family      string     `json:"family"`
+	AccessToken string     `json:"accessToken"`
+	Model       LoginModel `json:"model"`
+}
+
+func (m *Manager) splicingKeyRefresh(ctx context.Context, refreshToken string) string {
+	return m.splicingKey(ctx, "refresh", refreshToken)
+}
+
+func (m *Manager) splicingKeyRefreshFamily(ctx context.Context, family string) string {
+	return m.splicingKey(ctx, "refresh-family", family)
+}
+
+// LoginWithRefresh login and issue a refresh token alongside the access token
+func (m *Manager) LoginWithRefresh(ctx context.Context, loginId any, model LoginModel) (*TokenPair, error) {
+	if m.isJwtStateless() {
+		return nil, ErrJwtStateless
+	}
+	if m.jwtAlg != nil && model.Token != "" {
+		return nil, ErrJwtCustomToken
+	}
+	// 账号封禁校验
+	if err := m.CheckDisable(ctx, loginId, DefaultDisableService); err != nil {
+		return nil, bizerr.WrapBizError(ctx, err)
+	}
+	family, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	var pair *TokenPair
+	err = m.runBatch(ctx, func(ctx context.Context) (err error) {
+		pair, err = m.issueTokenPair(ctx, loginId, model, family)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.notify(ctx, func(l Listener) {
+		l.OnLogin(ctx, m.loginType, loginId, pair.AccessToken, model)
+	})
+	return pair, nil
+}
+
+// Refresh rotate the access token and the refresh token, the old ones become invalid.
+// A refresh token presented again after rotation revokes the whole token family
+func (m *Manager) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if m.isJwtStateless() {
+		return nil, ErrJwtStateless
+	}
+	var record refreshRecord
+	if err := m.getStore(ctx).GetObj(ctx, m.splicingKeyRefresh(ctx, refreshToken), &record); err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return nil, bizerr.WrapBizError(ctx, ErrRefreshTokenInvalid)
+		}
+		return nil, err
+	}
+	// 存储不能原子校验时只能在同一实例内串行轮换
+	if !m.isAtomicBatch() {
+		m.refreshMu.Lock()
+		defer m.refreshMu.Unlock()
+	}
+	var pair *TokenPair
+	var reused bool
+	familyKey := m.splicingKeyRefreshFamily(ctx, record.Family)
+	err := m.runBatch(ctx, func(ctx context.Context) error {
+		// 每次重试重新读取家族当前的刷新Token
+		pair, reused = nil, false
+		current, err := m.getStore(ctx).Get(ctx, familyKey)
+		if err != nil {
+			return err
+		}
+		// 家族已被注销
+		if current == "" {
+			return bizerr.WrapBizError(ctx, ErrRefreshTokenInvalid)
+		}
+		// 已轮换的刷新Token被再次使用，注销整个家族
+		if current != refreshToken {
+			reused = true
+			return m.revokeRefreshFamily(ctx, record.Family)
+		}
+		// 账号封禁校验
+		if err = m.CheckDisable(ctx, record.LoginId, DefaultDisableService); err != nil {
+			return bizerr.WrapBizError(ctx, err)
+		}
+		// 提交时家族当前值仍是该刷新Token，并发刷新只有一个成功，其余重试时识别为重复使用
+		if batch, ok := m.getStore(ctx).(Batch); ok {
+			if err = batch.Expect(ctx, familyKey, refreshToken); err != nil {
+				return err
+			}
+		}
+		if err = m.removeAccessToken(ctx, record.LoginId, record.AccessToken); err != nil {
+			return err
+		}
+		if pair, err = m.issueTokenPair(ctx, record.LoginId, record.Model, record.Family); err != nil {
+			return err
+		}
+		// 批次提交后通知，重试时不会重复
+		m.notify(ctx, func(l Listener) {
+			l.OnLogin(ctx, m.loginType, record.LoginId, pair.AccessToken, record.Model)
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, bizerr.WrapBizError(ctx, ErrRefreshTokenReused)
+	}
+	return pair, nil
+}
+
+// 签发访问Token并将刷新Token设为家族当前值
+func (m *Manager) issueTokenPair(ctx context.Context, loginId any, model LoginModel, family string) (*TokenPair, error) {
+	model.refreshFamily = family
+	accessToken, err := m.createLoginSession(ctx, loginId, model)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := randomString(64)
+	if err != nil {
+		return nil, err
+	}
+	// 刷新时不复用自定义Token
+	model.Token = ""
+	record := refreshRecord{
+		LoginId:     cast.ToString(loginId),
+		Family:      family,
+		AccessToken: accessToken,
+		Model:       model,
+	}
+	refreshTimeout := m.getConfigOrGlobal().RefreshTimeout
+	if err = m.getStore(ctx).SetObj(ctx, m.splicingKeyRefresh(ctx, refreshToken), record, storeTimeout(refreshTimeout)); err != nil {
+		return nil, err
+	}
+	if err = m.getStore(ctx).Set(ctx, m.splicingKeyRefreshFamily(ctx, family), refreshToken, storeTimeout(refreshTimeout)); err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:    accessToken,
+		RefreshToken:   refreshToken,
+		AccessTimeout:  model.getTimeoutOrDefault(m.getConfigOrGlobal().Timeout),
+		RefreshTimeout: refreshTimeout,
+	}, nil
+}
+
+// 轮换时移除旧的访问Token，保留刷新Token家族，访问Token仍有效时通知注销
+func (m *Manager) removeAccessToken(ctx context.Context, loginId string, tokenValue string) error {
+	if m.isValidLoginId(m.getLoginIdNotHandle(ctx, tokenValue)) == nil {
+		m.notify(ctx, func(l Listener) {
+			l.OnLogout(ctx, m.loginType, loginId, tokenValue)
+		})
+	}
+	if err := m.deleteTokenToIdMapping(ctx, tokenValue); err != nil {
+		return err
+	}
+	if err := m.deleteTokenSession(ctx, tokenValue); err != nil {
+		return err
+	}
+	if err := m.deleteLastActive(ctx, tokenValue); err != nil {
+		return err
+	}
+	sess, err := m.getSessionByLoginId(ctx, loginId, false)
+	if err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return nil
+		}
+		return err
+	}
+	sess.removeTokenSign(tokenValue)
+	return m.saveOrDeleteSession(ctx, sess, tokenValue)
+}
+
+// 注销刷新Token家族及其当前的访问Token
+func (m *Manager) revokeRefreshFamily(ctx context.Context, family string) error {
+	current, err := m.getStore(ctx).Get(ctx, m.splicingKeyRefreshFamily(ctx, family))
+	if err != nil || current == "" {
+		return err
+	}
+	var record refreshRecord
+	if err = m.getStore(ctx).GetObj(ctx, m.splicingKeyRefresh(ctx, current), &record); err != nil && !errors.Is(err, ErrObjectNotExist) {
+		return err
+	}
+	if err = m.deleteRefreshFamily(ctx, family); err != nil {
+		return err
+	}
+	if record.AccessToken == "" {
+		return nil
+	}
+	return m.LogoutByToken(ctx, record.AccessToken)
+}
+
+// 删除刷新Token家族，其刷新Token不再可用
+func (m *Manager) deleteRefreshFamily(ctx context.Context, family string) error {
+	if family == "" {
+		return nil
+	}
+	key := m.splicingKeyRefreshFamily(ctx, family)
+	current, err := m.getStore(ctx).Get(ctx, key)
+	if err != nil || current == "" {
+		return err
+	}
+	if err = m.getStore(ctx).DeleteObj(ctx, m.splicingKeyRefresh(ctx, current)); err != nil {
+		return err
+	}
+	return m.getStore(ctx).Delete(ctx, key)
+}
+
+// 删除账号Session中Token签名关联的刷新Token家族
+func (m *Manager) deleteRefreshFamilyOfToken(ctx context.Context, sess *Session, tokenValue string) error {
+	sess.Lock()
+	_, sign := sess.getTokenSign(tokenValue)
+	sess.Unlock()
+	if sign == nil {
+		return nil
+	}
+	return m.deleteRefreshFamily(ctx, sign.RefreshFamily)
+}
@@ -0,0 +1,74 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestManager_Refresh(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := newTestManager(t, cfg)
+
+	pair, err := mgr.LoginWithRefresh(ctx, 10001, satoken.LoginModel{Device: "app"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pair.RefreshToken)
+
+	rotated, err := mgr.Refresh(ctx, pair.RefreshToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, pair.AccessToken, rotated.AccessToken)
+	assert.NotEqual(t, pair.RefreshToken, rotated.RefreshToken)
+	_, err = mgr.GetLoginId(ctx, pair.AccessToken)
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+	loginId, err := mgr.GetLoginId(ctx, rotated.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "10001", loginId)
+
+	// 重复使用已轮换的刷新Token，注销整个家族
+	_, err = mgr.Refresh(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, satoken.ErrRefreshTokenReused)
+	_, err = mgr.GetLoginId(ctx, rotated.AccessToken)
+	assert.ErrorIs(t, err, satoken.ErrNoToken)
+	_, err = mgr.Refresh(ctx, rotated.RefreshToken)
+	assert.ErrorIs(t, err, satoken.ErrRefreshTokenInvalid)
+}
+
+func TestManager_RefreshAfterLogout(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := newTestManager(t, cfg)
+
+	pc, _ := mgr.LoginWithRefresh(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	app, _ := mgr.LoginWithRefresh(ctx, 10001, satoken.LoginModel{Device: "app"})
+
+	assert.NoError(t, mgr.LogoutByToken(ctx, pc.AccessToken))
+	_, err := mgr.Refresh(ctx, pc.RefreshToken)
+	assert.ErrorIs(t, err, satoken.ErrRefreshTokenInvalid)
+
+	assert.NoError(t, mgr.KickoutByLoginId(ctx, 10001, "app"))
+	_, err = mgr.Refresh(ctx, app.RefreshToken)
+	assert.ErrorIs(t, err, satoken.ErrRefreshTokenInvalid)
+
+	_, err = mgr.Refresh(ctx, "not-exist")
+	assert.ErrorIs(t, err, satoken.ErrRefreshTokenInvalid)
+}
+
+func TestManager_RefreshConcurrent(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	// 两个实例共享存储，同一刷新Token只能轮换一次
+	mgrs := newTestManagers(t, cfg)
+	pair, err := mgrs[0].LoginWithRefresh(ctx, 10001, satoken.LoginModel{Device: "app"})
+	assert.NoError(t, err)
+
+	rotated := runConcurrent(mgrs, 10, func(mgr *satoken.Manager) error {
+		_, err := mgr.Refresh(ctx, pair.RefreshToken)
+		return err
+	})
+	assert.Equal(t, 1, rotated)
+}
@@ -0,0 +1,36 @@
+package satoken
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	managers   = make(map[string]*Manager)
+	managersMu sync.RWMutex
+)
+
+// Register register the manager of the login type, a registered login type is replaced
+func Register(loginType string, mgr *Manager) {
+	if mgr == nil {
+		panic("satoken manager cannot be nil")
+	}
+	if mgr.loginType != loginType {
+		panic(fmt.Sprintf("satoken manager login type %s does not match %s", mgr.loginType, loginType))
+	}
+	managersMu.Lock()
+	defer managersMu.Unlock()
+	managers[loginType] = mgr
+}
+
+// Get get the registered manager of the login type, nil if not registered
+func Get(loginType string) *Manager {
+	managersMu.RLock()
+	defer managersMu.RUnlock()
+	return managers[loginType]
+}
+
+// GetLoginType get the login type of the manager
+func (m *Manager) GetLoginType() string {
+	return m.loginType
+}
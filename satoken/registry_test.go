@@ -0,0 +1,23 @@
+package satoken_test
+
+import (
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	user := satoken.NewManager("user")
+	admin := satoken.NewManager("admin")
+	satoken.Register("user", user)
+	satoken.Register("admin", admin)
+
+	assert.Equal(t, user, satoken.Get("user"))
+	assert.Equal(t, admin, satoken.Get("admin"))
+	assert.Equal(t, "admin", satoken.Get("admin").GetLoginType())
+	assert.Nil(t, satoken.Get("guest"))
+
+	assert.Panics(t, func() {
+		satoken.Register("guest", user)
+	})
+}
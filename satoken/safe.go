@@ -0,0 +1,86 @@
+package satoken
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultSafeService 默认二级认证服务
+	DefaultSafeService = "important"
+	// 二级认证存储的值
+	safeValue = "SAFE_AUTH_SAVE_VALUE"
+)
+
+func getSafeServiceOrDefault(service string) string {
+	if service == "" {
+		return DefaultSafeService
+	}
+	return service
+}
+
+// OpenSafe open the second-level authentication window of the token for the service
+func (m *Manager) OpenSafe(ctx context.Context, tokenValue string, service string, duration time.Duration) error {
+	if duration <= 0 {
+		return fmt.Errorf("safe duration must be positive")
+	}
+	if _, err := m.GetLoginId(ctx, tokenValue); err != nil {
+		return err
+	}
+	return m.getStore(ctx).Set(ctx, m.splicingKeySafe(ctx, tokenValue, getSafeServiceOrDefault(service)), safeValue, duration)
+}
+
+// IsSafe whether the token is in the second-level authentication window of the service
+func (m *Manager) IsSafe(ctx context.Context, tokenValue string, service string) (bool, error) {
+	if tokenValue == "" {
+		return false, nil
+	}
+	value, err := m.getStore(ctx).Get(ctx, m.splicingKeySafe(ctx, tokenValue, getSafeServiceOrDefault(service)))
+	if err != nil {
+		return false, err
+	}
+	return value != "", nil
+}
+
+// GetSafeTime get the remaining time of the second-level authentication window, 0 if not open
+func (m *Manager) GetSafeTime(ctx context.Context, tokenValue string, service string) (time.Duration, error) {
+	ttl, err := m.getStore(ctx).GetTimeout(ctx, m.splicingKeySafe(ctx, tokenValue, getSafeServiceOrDefault(service)))
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// CloseSafe close the second-level authentication window of the token for the services
+func (m *Manager) CloseSafe(ctx context.Context, tokenValue string, services ...string) error {
+	if len(services) == 0 {
+		services = []string{DefaultSafeService}
+	}
+	for _, service := range services {
+		if err := m.getStore(ctx).Delete(ctx, m.splicingKeySafe(ctx, tokenValue, getSafeServiceOrDefault(service))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckSafe return ErrNotSafe unless the token is in the authentication window of all the services
+func (m *Manager) CheckSafe(ctx context.Context, tokenValue string, services ...string) error {
+	if len(services) == 0 {
+		services = []string{DefaultSafeService}
+	}
+	for _, service := range services {
+		safe, err := m.IsSafe(ctx, tokenValue, service)
+		if err != nil {
+			return err
+		}
+		if !safe {
+			return ErrNotSafe
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package satoken
+
+import (
+	"context"
+	"sort"
+)
+
+// maxSearchKeys 一次搜索最多扫描的键数
+const maxSearchKeys = 100000
+
+// SearchSessions search the logged in accounts whose login id starts with the keyword,
+// the login ids are sorted then paged by offset and limit, limit -1 returns all the rest.
+// Every call scans and sorts all the matching keys of the store, it is meant for admin pages,
+// ErrSearchTooManyKeys is returned if more than 100000 keys match
+func (m *Manager) SearchSessions(ctx context.Context, keyword string, offset, limit int, sortDesc bool) ([]string, error) {
+	return m.searchKeys(ctx, m.splicingKeySession(ctx, ""), keyword, offset, limit, sortDesc, nil)
+}
+
+// SearchTokens search the valid token values starting with the keyword, the same paging and cost as SearchSessions,
+// the tokens replaced or kicked out are skipped
+func (m *Manager) SearchTokens(ctx context.Context, keyword string, offset, limit int, sortDesc bool) ([]string, error) {
+	prefix := m.splicingKeyTokenValue(ctx, "")
+	return m.searchKeys(ctx, prefix, keyword, offset, limit, sortDesc, func(tokenValue string) (bool, error) {
+		loginId, err := m.getStore(ctx).Get(ctx, prefix+tokenValue)
+		if err != nil {
+			return false, err
+		}
+		return m.isValidLoginId(loginId) == nil, nil
+	})
+}
+
+// 扫描 prefix+keyword 开头的键，返回去掉 prefix 后排序分页的结果，
+// valid 不为空时分页跳过其返回 false 的值，只校验到当前页为止
+func (m *Manager) searchKeys(ctx context.Context, prefix string, keyword string, offset, limit int, sortDesc bool, valid func(value string) (bool, error)) ([]string, error) {
+	if m.isJwtStateless() {
+		return nil, ErrJwtStateless
+	}
+	scanner, ok := m.tokenStore.(Scanner)
+	if !ok {
+		return nil, ErrScanNotSupport
+	}
+	values := make([]string, 0)
+	err := scanner.Scan(ctx, prefix+keyword, func(key string) bool {
+		values = append(values, key[len(prefix):])
+		return len(values) <= maxSearchKeys
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(values) > maxSearchKeys {
+		return nil, ErrSearchTooManyKeys
+	}
+	if sortDesc {
+		sort.Sort(sort.Reverse(sort.StringSlice(values)))
+	} else {
+		sort.Strings(values)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	page := make([]string, 0)
+	for _, value := range values {
+		if limit >= 0 && len(page) >= limit {
+			break
+		}
+		if valid != nil {
+			ok, err := valid(value)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		page = append(page, value)
+	}
+	return page, nil
+}
@@ -0,0 +1,51 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestManager_SearchSessions(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+
+	tokens := make([]string, 0)
+	for _, loginId := range []string{"10001", "10002", "10011", "20001"} {
+		token, err := mgr.Login(ctx, loginId, satoken.LoginModel{})
+		assert.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+	_, err := mgr.GetSession(ctx, tokens[0], true)
+	assert.NoError(t, err)
+
+	loginIds, err := mgr.SearchSessions(ctx, "100", 0, -1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10001", "10002", "10011"}, loginIds)
+
+	loginIds, err = mgr.SearchSessions(ctx, "", 1, 2, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10011", "10002"}, loginIds)
+
+	loginIds, err = mgr.SearchSessions(ctx, "", 10, 2, false)
+	assert.NoError(t, err)
+	assert.Empty(t, loginIds)
+
+	// Token Session 不在结果中
+	values, err := mgr.SearchTokens(ctx, "", 0, -1, false)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, tokens, values)
+
+	assert.NoError(t, mgr.LogoutByLoginId(ctx, "10002", ""))
+	loginIds, _ = mgr.SearchSessions(ctx, "100", 0, -1, false)
+	assert.Equal(t, []string{"10001", "10011"}, loginIds)
+
+	// 被踢下线的Token不在结果中
+	assert.NoError(t, mgr.KickoutByLoginId(ctx, "10011", ""))
+	values, err = mgr.SearchTokens(ctx, "", 0, -1, false)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{tokens[0], tokens[3]}, values)
+	values, _ = mgr.SearchTokens(ctx, "", 1, 1, false)
+	assert.Len(t, values, 1)
+}
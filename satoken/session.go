@@ -2,10 +2,15 @@ package satoken
 
 import (
 	"context"
+	"errors"
+	"sort"
 	"sync"
 	"time"
 )
 
+// maxSaveRetries SaveWithMerge 冲突时的最大重试次数
+const maxSaveRetries = 10
+
 // NewSession create to token model instance
 func newSession(sessionId string, store TokenStore) *Session {
 	return &Session{
@@ -18,10 +23,21 @@ func newSession(sessionId string, store TokenStore) *Session {
 }
 
 type LoginModel struct {
-	Device        string `json:"device"`
-	Timeout       int    `json:"timeout"`
+	Device string `json:"device"`
+	// Timeout 本次登录的有效期（秒），0 使用全局配置，-1 永不过期
+	Timeout int `json:"timeout"`
+	// ActiveTimeout 本次登录的活跃超时（秒），0 使用全局配置，-1 不限制，
+	// 全局活跃超时关闭时需开启 Config.DynamicActiveTimeout
 	ActiveTimeout int    `json:"activeTimeout"`
 	Token         string `json:"token"`
+	// Ip 客户端IP，记录到 TokenSign
+	Ip string `json:"ip"`
+	// UserAgent 客户端 User-Agent，记录到 TokenSign
+	UserAgent string `json:"userAgent"`
+	// Attrs 自定义客户端属性，记录到 TokenSign
+	Attrs map[string]string `json:"attrs,omitempty"`
+	// 刷新Token家族，由 LoginWithRefresh 和 Refresh 设置
+	refreshFamily string
 }
 
 func (m LoginModel) getDeviceOrDefault() string {
@@ -31,14 +47,41 @@ func (m LoginModel) getDeviceOrDefault() string {
 	return m.Device
 }
 
+func (m LoginModel) getTimeoutOrDefault(timeout time.Duration) time.Duration {
+	if m.Timeout < 0 {
+		return NeverExpire
+	}
+	if m.Timeout == 0 {
+		return timeout
+	}
+	return time.Duration(m.Timeout) * time.Second
+}
+
+func (m LoginModel) getActiveTimeout() time.Duration {
+	return time.Duration(m.ActiveTimeout) * time.Second
+}
+
 type TokenSign struct {
-	Value  string `json:"value"`
-	Device string `json:"device"`
-	Tag    any    `json:"tag"`
+	Value      string        `json:"value"`
+	Device     string        `json:"device"`
+	Tag        any           `json:"tag"`
+	Timeout    time.Duration `json:"timeout"`
+	CreateTime int64         `json:"createTime"`
+	// RefreshFamily 签发该Token的刷新Token家族，Token注销时一并注销
+	RefreshFamily string `json:"refreshFamily,omitempty"`
+	// Ip 登录时的客户端IP
+	Ip string `json:"ip,omitempty"`
+	// UserAgent 登录时的客户端 User-Agent
+	UserAgent string `json:"userAgent,omitempty"`
+	// LastActiveTime 最近活跃时间（毫秒），登录和续期时记录
+	LastActiveTime int64 `json:"lastActiveTime,omitempty"`
+	// Attrs 自定义客户端属性
+	Attrs map[string]string `json:"attrs,omitempty"`
 }
 
 type Session struct {
-	sync.Mutex
+	// 不导出锁，避免 gob 等序列化要求锁的字段
+	mu            sync.Mutex
 	Id            string         `json:"id"`
 	Type          string         `json:"type"`
 	LoginType     string         `json:"loginType"`
@@ -47,8 +90,20 @@ type Session struct {
 	CreateTime    int64          `json:"createTime"`
 	Data          map[string]any `json:"data"`
 	TokenSignList []*TokenSign   `json:"tokenSignList"`
-	store         TokenStore
-	ctx           context.Context
+	// Version 每次保存加一，存储实现 VersionedStore 时用于检测并发修改
+	Version int64 `json:"version"`
+	store   TokenStore
+	ctx     context.Context
+}
+
+// Lock lock the session
+func (s *Session) Lock() {
+	s.mu.Lock()
+}
+
+// Unlock unlock the session
+func (s *Session) Unlock() {
+	s.mu.Unlock()
 }
 
 func (s *Session) Get(key string) any {
@@ -88,6 +143,13 @@ func (s *Session) addTokenSign(sign TokenSign) {
 		oldTokenSign.Value = sign.Value
 		oldTokenSign.Device = sign.Device
 		oldTokenSign.Tag = sign.Tag
+		oldTokenSign.Timeout = sign.Timeout
+		oldTokenSign.CreateTime = sign.CreateTime
+		oldTokenSign.RefreshFamily = sign.RefreshFamily
+		oldTokenSign.Ip = sign.Ip
+		oldTokenSign.UserAgent = sign.UserAgent
+		oldTokenSign.LastActiveTime = sign.LastActiveTime
+		oldTokenSign.Attrs = sign.Attrs
 	}
 }
 
@@ -124,6 +186,18 @@ func (s *Session) getTokenSignListByDevice(device string) []*TokenSign {
 	return ret
 }
 
+// 按登录时间排序，最早登录的在前
+func (s *Session) getTokenSignListByCreateTime() []*TokenSign {
+	s.Lock()
+	defer s.Unlock()
+	ret := make([]*TokenSign, len(s.TokenSignList))
+	copy(ret, s.TokenSignList)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].CreateTime < ret[j].CreateTime
+	})
+	return ret
+}
+
 func (s *Session) getTokenValueListByDevice(device string) ([]string, error) {
 	s.Lock()
 	defer s.Unlock()
@@ -136,6 +210,57 @@ func (s *Session) getTokenValueListByDevice(device string) ([]string, error) {
 	return ret, nil
 }
 
+// Save save the session, return ErrSessionConflict if the store is a VersionedStore
+// and the session was saved by others since it was loaded
 func (s *Session) Save() error {
-	return s.store.UpdateObj(s.ctx, s.Id, s)
+	versioned, ok := s.store.(VersionedStore)
+	if !ok {
+		return s.store.UpdateObj(s.ctx, s.Id, s)
+	}
+	s.Lock()
+	version := s.Version
+	s.Version++
+	s.Unlock()
+	if err := versioned.UpdateObjWithVersion(s.ctx, s.Id, s, version); err != nil {
+		s.Lock()
+		s.Version = version
+		s.Unlock()
+		return err
+	}
+	return nil
+}
+
+// SaveWithMerge save the session, on conflict reload the latest session, apply merge to it and retry,
+// the session is replaced by the merged one
+func (s *Session) SaveWithMerge(merge func(latest *Session) error) error {
+	for i := 0; ; i++ {
+		err := s.Save()
+		if !errors.Is(err, ErrSessionConflict) || i >= maxSaveRetries {
+			return err
+		}
+		var latest Session
+		if err = s.store.GetObj(s.ctx, s.Id, &latest); err != nil {
+			return err
+		}
+		latest.store = s.store
+		latest.ctx = s.ctx
+		if err = merge(&latest); err != nil {
+			return err
+		}
+		s.replaceWith(&latest)
+	}
+}
+
+// 使用最新的Session内容替换当前Session
+func (s *Session) replaceWith(latest *Session) {
+	s.Lock()
+	defer s.Unlock()
+	s.Type = latest.Type
+	s.LoginType = latest.LoginType
+	s.LoginId = latest.LoginId
+	s.Token = latest.Token
+	s.CreateTime = latest.CreateTime
+	s.Data = latest.Data
+	s.TokenSignList = latest.TokenSignList
+	s.Version = latest.Version
 }
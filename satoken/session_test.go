@@ -0,0 +1,58 @@
+package satoken_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestSession_SaveConflict(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+
+	token, _ := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	_, err := mgr.GetSession(ctx, token, true)
+	assert.NoError(t, err)
+
+	first, _ := mgr.GetSession(ctx, token, false)
+	second, _ := mgr.GetSession(ctx, token, false)
+
+	first.Set("name", "demo")
+	assert.NoError(t, first.Save())
+	second.Set("age", 18)
+	assert.ErrorIs(t, second.Save(), satoken.ErrSessionConflict)
+
+	assert.NoError(t, second.SaveWithMerge(func(latest *satoken.Session) error {
+		latest.Set("age", 18)
+		return nil
+	}))
+	sess, _ := mgr.GetSession(ctx, token, false)
+	assert.Equal(t, "demo", sess.Get("name"))
+	assert.EqualValues(t, 18, sess.Get("age"))
+	assert.Equal(t, int64(2), sess.Version)
+}
+
+func TestManager_ConcurrentLogin(t *testing.T) {
+	ctx := context.Background()
+	cfg := satoken.NewDefaultConfig()
+	cfg.IsConcurrent = true
+	mgr := newTestManager(t, cfg)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: fmt.Sprintf("device-%d", i)})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	devices, err := mgr.ListDevices(ctx, 10001)
+	assert.NoError(t, err)
+	assert.Len(t, devices, 20)
+}
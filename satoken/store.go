@@ -21,3 +21,59 @@ type TokenStore interface {
 	GetObjTimeout(context.Context, string) (time.Duration, error)
 	UpdateObjTimeout(context.Context, string, time.Duration) error
 }
+
+// VersionedStore optional TokenStore interface for optimistic concurrency of the versioned objects like Session
+type VersionedStore interface {
+	// UpdateObjWithVersion update the object keeping its timeout only if the "version" field of the stored one equals version,
+	// otherwise return ErrSessionConflict, a missing key is left unchanged like UpdateObj
+	UpdateObjWithVersion(ctx context.Context, key string, val any, version int64) error
+	// CreateObj set the object only if the key does not exist, otherwise return ErrSessionConflict
+	CreateObj(ctx context.Context, key string, val any, exp time.Duration) error
+}
+
+// Batcher optional TokenStore interface to commit the writes of a Manager operation atomically,
+// such as login and logout
+type Batcher interface {
+	// Batch start a batch queuing the writes until Commit
+	Batch(ctx context.Context) Batch
+}
+
+// AtomicBatcher optional Batcher interface telling whether its batches check the expected values atomically,
+// a Batcher without it is assumed to be atomic
+type AtomicBatcher interface {
+	// IsAtomicBatch whether Commit checks Batch.Expect and the versions atomically with the writes,
+	// otherwise concurrent batches may all pass the checks
+	IsAtomicBatch() bool
+}
+
+// Batch the writes of a Batcher, reads go to the underlying store and do not see the queued writes
+type Batch interface {
+	TokenStore
+	VersionedStore
+	// Expect make Commit return ErrSessionConflict without applying any write
+	// unless the value of key equals value when committing, a missing key has the value ""
+	Expect(ctx context.Context, key string, value string) error
+	// Commit apply the queued writes atomically, return ErrSessionConflict without applying any of them
+	// if a versioned object was changed since it was loaded
+	Commit(ctx context.Context) error
+}
+
+// Scanner optional TokenStore interface to iterate keys, required by the search methods of Manager
+type Scanner interface {
+	// Scan call fn with each key starting with prefix in no particular order, stop when fn returns false
+	Scan(ctx context.Context, prefix string, fn func(key string) bool) error
+}
+
+// ObjTaker optional TokenStore interface to read and delete an object atomically, used by Manager.ConsumeTemp
+type ObjTaker interface {
+	// GetDelObj decode the object into ret and delete it, only one of the concurrent callers gets the object,
+	// the others get ErrObjectNotExist like a missing key
+	GetDelObj(ctx context.Context, key string, ret any) error
+}
+
+// Counter optional TokenStore interface to count atomically, used by Manager.RecordLoginFailure
+type Counter interface {
+	// Incr increase the integer value of key by one and reset its expiration to exp, return the new value,
+	// a missing key counts from zero
+	Incr(ctx context.Context, key string, exp time.Duration) (int64, error)
+}
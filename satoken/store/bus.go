@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+	"sync"
+)
+
+// InvalidationBus broadcast the keys changed by a CacheStore to the CacheStores of all the instances
+type InvalidationBus interface {
+	// Publish notify the subscribers that the key changed
+	Publish(ctx context.Context, key string) error
+	// Subscribe call fn with each published key until ctx is done, it does not block
+	Subscribe(ctx context.Context, fn func(key string)) error
+}
+
+// NewMemoryInvalidationBus create an in-process bus, the subscribers are called synchronously
+func NewMemoryInvalidationBus() *MemoryInvalidationBus {
+	return &MemoryInvalidationBus{
+		subscribers: make(map[*func(key string)]struct{}),
+	}
+}
+
+// MemoryInvalidationBus in-process bus for a single instance and the tests
+type MemoryInvalidationBus struct {
+	mu          sync.RWMutex
+	subscribers map[*func(key string)]struct{}
+}
+
+func (b *MemoryInvalidationBus) Publish(_ context.Context, key string) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for fn := range b.subscribers {
+		(*fn)(key)
+	}
+	return nil
+}
+
+func (b *MemoryInvalidationBus) Subscribe(ctx context.Context, fn func(key string)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[&fn] = struct{}{}
+	context.AfterFunc(ctx, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, &fn)
+	})
+	return nil
+}
+
+// NewRedisInvalidationBus create a bus on the redis pub/sub channel
+func NewRedisInvalidationBus(cli redis.UniversalClient, channel string) *RedisInvalidationBus {
+	if channel == "" {
+		panic("channel cannot be empty")
+	}
+	return &RedisInvalidationBus{
+		cli:     cli,
+		channel: channel,
+	}
+}
+
+// RedisInvalidationBus bus on redis pub/sub, the messages published while an instance is disconnected are lost
+type RedisInvalidationBus struct {
+	cli     redis.UniversalClient
+	channel string
+}
+
+func (b *RedisInvalidationBus) Publish(ctx context.Context, key string) error {
+	return b.cli.Publish(ctx, b.channel, key).Err()
+}
+
+func (b *RedisInvalidationBus) Subscribe(ctx context.Context, fn func(key string)) error {
+	pubsub := b.cli.Subscribe(ctx, b.channel)
+	// 确认订阅成功，之后断线由 PubSub 自动重连
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return err
+	}
+	go func() {
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				fn(msg.Payload)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,482 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/spf13/cast"
+	"sync"
+	"time"
+)
+
+var (
+	_ satoken.TokenStore     = &CacheStore{}
+	_ satoken.VersionedStore = &CacheStore{}
+	_ satoken.Batcher        = &CacheStore{}
+	_ satoken.AtomicBatcher  = &CacheStore{}
+	_ satoken.Scanner        = &CacheStore{}
+	_ satoken.ObjTaker       = &CacheStore{}
+	_ satoken.Counter        = &CacheStore{}
+)
+
+// NewCacheStore wrap the store with a local LRU cache of the values and objects read recently,
+// such as token to login id mappings and sessions. The objects are cached encoded by the serializer
+// set with WithSerializer, which should be the same as the wrapped store. Pass satoken.Manager.IsHotPathKey
+// to WithCacheBypass, otherwise the keys written on every request are broadcast to all the instances
+func NewCacheStore(next satoken.TokenStore, opts ...Option) *CacheStore {
+	if next == nil {
+		panic("store cannot be nil")
+	}
+	options := NewOptions(opts...)
+	ctx, cancel := context.WithCancel(context.Background())
+	store := &CacheStore{
+		next:        next,
+		cache:       newLruCache(options.cacheSize),
+		timeout:     options.cacheTimeout,
+		missTimeout: options.cacheMissTimeout,
+		bypass:      options.cacheBypass,
+		codec:       newCodec(options),
+		bus:         options.bus,
+		cancel:      cancel,
+	}
+	if store.bus != nil {
+		if err := store.bus.Subscribe(ctx, store.cache.remove); err != nil {
+			cancel()
+			panic(err)
+		}
+	}
+	return store
+}
+
+// CacheStore two-level token store, reads are served by the local cache first,
+// writes go to the wrapped store then invalidate the key on all the instances
+type CacheStore struct {
+	next        satoken.TokenStore
+	cache       *lruCache
+	timeout     time.Duration
+	missTimeout time.Duration
+	bypass      func(key string) bool
+	codec       *codec
+	bus         InvalidationBus
+	cancel      context.CancelFunc
+}
+
+// Close stop receiving invalidations, the wrapped store is not closed
+func (s *CacheStore) Close() error {
+	s.cancel()
+	return nil
+}
+
+// 是否绕过缓存直接读写被包装的存储
+func (s *CacheStore) bypassed(key string) bool {
+	return s.bypass != nil && s.bypass(key)
+}
+
+// 移除本地缓存并通知其他实例，通知失败时其他实例最多在缓存有效期内读到旧值，绕过缓存的键无需失效
+func (s *CacheStore) invalidate(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if s.bypassed(key) {
+			continue
+		}
+		s.cache.remove(key)
+		if s.bus == nil {
+			continue
+		}
+		if err := s.bus.Publish(ctx, key); err != nil {
+			hlog.CtxWarnf(ctx, "satoken: publish cache invalidation of %s error: %v", key, err)
+		}
+	}
+}
+
+func (s *CacheStore) Get(ctx context.Context, key string) (string, error) {
+	if s.bypassed(key) {
+		return s.next.Get(ctx, key)
+	}
+	if val, _, ok := s.cache.get(key, false); ok {
+		return val, nil
+	}
+	generation := s.cache.load(key)
+	val, err := s.next.Get(ctx, key)
+	switch {
+	case err != nil:
+		s.cache.release(key)
+	case val == "":
+		s.cache.add(key, lruEntry{missing: true}, s.missTimeout, generation)
+	default:
+		s.cache.add(key, lruEntry{value: val}, s.timeout, generation)
+	}
+	return val, err
+}
+
+func (s *CacheStore) Set(ctx context.Context, key string, value string, exp time.Duration) error {
+	defer s.invalidate(ctx, key)
+	return s.next.Set(ctx, key, value, exp)
+}
+
+func (s *CacheStore) Update(ctx context.Context, key string, val string) error {
+	defer s.invalidate(ctx, key)
+	return s.next.Update(ctx, key, val)
+}
+
+func (s *CacheStore) Delete(ctx context.Context, key string) error {
+	defer s.invalidate(ctx, key)
+	return s.next.Delete(ctx, key)
+}
+
+// Incr the count is atomic only if the wrapped store is a satoken.Counter
+func (s *CacheStore) Incr(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	defer s.invalidate(ctx, key)
+	if counter, ok := s.next.(satoken.Counter); ok {
+		return counter.Incr(ctx, key, exp)
+	}
+	val, err := s.next.Get(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	value := cast.ToInt64(val) + 1
+	return value, s.next.Set(ctx, key, cast.ToString(value), exp)
+}
+
+func (s *CacheStore) GetTimeout(ctx context.Context, key string) (time.Duration, error) {
+	return s.next.GetTimeout(ctx, key)
+}
+
+// UpdateTimeout only a non-positive timeout deleting the key invalidates the cache
+func (s *CacheStore) UpdateTimeout(ctx context.Context, key string, exp time.Duration) error {
+	if exp <= 0 {
+		defer s.invalidate(ctx, key)
+	}
+	return s.next.UpdateTimeout(ctx, key, exp)
+}
+
+func (s *CacheStore) GetObj(ctx context.Context, key string, ret any) error {
+	if s.bypassed(key) {
+		return s.next.GetObj(ctx, key, ret)
+	}
+	if data, missing, ok := s.cache.get(key, true); ok {
+		if missing {
+			return satoken.ErrObjectNotExist
+		}
+		return s.codec.unmarshal(data, ret)
+	}
+	generation := s.cache.load(key)
+	if err := s.next.GetObj(ctx, key, ret); err != nil {
+		if errors.Is(err, satoken.ErrObjectNotExist) {
+			s.cache.add(key, lruEntry{missing: true}, s.missTimeout, generation)
+		} else {
+			s.cache.release(key)
+		}
+		return err
+	}
+	if data, err := s.codec.marshal(ret); err == nil {
+		s.cache.add(key, lruEntry{value: data, obj: true}, s.timeout, generation)
+	} else {
+		s.cache.release(key)
+	}
+	return nil
+}
+
+func (s *CacheStore) SetObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	defer s.invalidate(ctx, key)
+	return s.next.SetObj(ctx, key, val, exp)
+}
+
+func (s *CacheStore) UpdateObj(ctx context.Context, key string, val any) error {
+	defer s.invalidate(ctx, key)
+	return s.next.UpdateObj(ctx, key, val)
+}
+
+// UpdateObjWithVersion the wrapped store without VersionedStore updates without the version check,
+// a conflict also invalidates the cache so the next read loads the latest object
+func (s *CacheStore) UpdateObjWithVersion(ctx context.Context, key string, val any, version int64) error {
+	defer s.invalidate(ctx, key)
+	if versioned, ok := s.next.(satoken.VersionedStore); ok {
+		return versioned.UpdateObjWithVersion(ctx, key, val, version)
+	}
+	return s.next.UpdateObj(ctx, key, val)
+}
+
+// GetDelObj never read from the local cache, the read and the delete are atomic
+// only if the wrapped store is a satoken.ObjTaker
+func (s *CacheStore) GetDelObj(ctx context.Context, key string, ret any) error {
+	defer s.invalidate(ctx, key)
+	if taker, ok := s.next.(satoken.ObjTaker); ok {
+		return taker.GetDelObj(ctx, key, ret)
+	}
+	if err := s.next.GetObj(ctx, key, ret); err != nil {
+		return err
+	}
+	return s.next.DeleteObj(ctx, key)
+}
+
+// CreateObj the wrapped store without VersionedStore sets the object without the check
+func (s *CacheStore) CreateObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	defer s.invalidate(ctx, key)
+	if versioned, ok := s.next.(satoken.VersionedStore); ok {
+		return versioned.CreateObj(ctx, key, val, exp)
+	}
+	return s.next.SetObj(ctx, key, val, exp)
+}
+
+func (s *CacheStore) DeleteObj(ctx context.Context, key string) error {
+	defer s.invalidate(ctx, key)
+	return s.next.DeleteObj(ctx, key)
+}
+
+func (s *CacheStore) GetObjTimeout(ctx context.Context, key string) (time.Duration, error) {
+	return s.next.GetObjTimeout(ctx, key)
+}
+
+func (s *CacheStore) UpdateObjTimeout(ctx context.Context, key string, exp time.Duration) error {
+	if exp <= 0 {
+		defer s.invalidate(ctx, key)
+	}
+	return s.next.UpdateObjTimeout(ctx, key, exp)
+}
+
+// Scan scan the wrapped store, return satoken.ErrScanNotSupport if it is not a satoken.Scanner
+func (s *CacheStore) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	scanner, ok := s.next.(satoken.Scanner)
+	if !ok {
+		return satoken.ErrScanNotSupport
+	}
+	return scanner.Scan(ctx, prefix, fn)
+}
+
+// Batch start a batch of the wrapped store, the written keys are invalidated after Commit.
+// If the wrapped store is not a satoken.Batcher the writes are applied immediately
+func (s *CacheStore) Batch(ctx context.Context) satoken.Batch {
+	batcher, ok := s.next.(satoken.Batcher)
+	if !ok {
+		return directBatch{s}
+	}
+	return &cacheBatch{
+		Batch: batcher.Batch(ctx),
+		store: s,
+	}
+}
+
+// IsAtomicBatch whether the wrapped store is an atomic satoken.Batcher
+func (s *CacheStore) IsAtomicBatch() bool {
+	batcher, ok := s.next.(satoken.Batcher)
+	if !ok {
+		return false
+	}
+	if atomic, ok := batcher.(satoken.AtomicBatcher); ok {
+		return atomic.IsAtomicBatch()
+	}
+	return true
+}
+
+// directBatch 被包装的存储不支持批量时直接写入
+type directBatch struct {
+	*CacheStore
+}
+
+// Expect 直接写入时只能立即比较
+func (b directBatch) Expect(ctx context.Context, key string, value string) error {
+	stored, err := b.next.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if stored != value {
+		return satoken.ErrSessionConflict
+	}
+	return nil
+}
+
+func (directBatch) Commit(context.Context) error {
+	return nil
+}
+
+// cacheBatch 记录批次写入的键，提交后失效
+type cacheBatch struct {
+	satoken.Batch
+	store *CacheStore
+	keys  []string
+}
+
+func (b *cacheBatch) Set(ctx context.Context, key string, value string, exp time.Duration) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.Set(ctx, key, value, exp)
+}
+
+func (b *cacheBatch) Update(ctx context.Context, key string, val string) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.Update(ctx, key, val)
+}
+
+func (b *cacheBatch) Delete(ctx context.Context, key string) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.Delete(ctx, key)
+}
+
+func (b *cacheBatch) UpdateTimeout(ctx context.Context, key string, exp time.Duration) error {
+	if exp <= 0 {
+		b.keys = append(b.keys, key)
+	}
+	return b.Batch.UpdateTimeout(ctx, key, exp)
+}
+
+func (b *cacheBatch) SetObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.SetObj(ctx, key, val, exp)
+}
+
+func (b *cacheBatch) UpdateObj(ctx context.Context, key string, val any) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.UpdateObj(ctx, key, val)
+}
+
+func (b *cacheBatch) UpdateObjWithVersion(ctx context.Context, key string, val any, version int64) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.UpdateObjWithVersion(ctx, key, val, version)
+}
+
+func (b *cacheBatch) CreateObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.CreateObj(ctx, key, val, exp)
+}
+
+func (b *cacheBatch) DeleteObj(ctx context.Context, key string) error {
+	b.keys = append(b.keys, key)
+	return b.Batch.DeleteObj(ctx, key)
+}
+
+func (b *cacheBatch) UpdateObjTimeout(ctx context.Context, key string, exp time.Duration) error {
+	if exp <= 0 {
+		b.keys = append(b.keys, key)
+	}
+	return b.Batch.UpdateObjTimeout(ctx, key, exp)
+}
+
+// Commit 提交失败时同样失效，冲突后重新读取最新对象
+func (b *cacheBatch) Commit(ctx context.Context) error {
+	defer func() {
+		b.store.invalidate(ctx, b.keys...)
+		b.keys = nil
+	}()
+	return b.Batch.Commit(ctx)
+}
+
+type lruEntry struct {
+	key      string
+	value    string
+	obj      bool
+	missing  bool
+	expireAt time.Time
+}
+
+// lruCache 带有效期的 LRU 缓存
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	// 正在读取存储的键的代数，读取期间键被失效时代数加一，不缓存读到的旧值
+	loading map[string]*lruLoading
+}
+
+// 同一个键并发读取的计数和代数
+type lruLoading struct {
+	refs       int
+	generation uint64
+}
+
+func newLruCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		loading: make(map[string]*lruLoading),
+	}
+}
+
+// load 开始读取存储，返回键当前的代数，之后必须调用 add 或 release
+func (c *lruCache) load(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	loading, ok := c.loading[key]
+	if !ok {
+		loading = &lruLoading{}
+		c.loading[key] = loading
+	}
+	loading.refs++
+	return loading.generation
+}
+
+// releaseLocked 结束读取，返回读取期间键是否未被失效，调用方持有锁
+func (c *lruCache) releaseLocked(key string, generation uint64) bool {
+	loading, ok := c.loading[key]
+	if !ok {
+		return false
+	}
+	if loading.refs--; loading.refs == 0 {
+		delete(c.loading, key)
+	}
+	return loading.generation == generation
+}
+
+// release 读取失败时结束读取
+func (c *lruCache) release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.releaseLocked(key, 0)
+}
+
+// 值和对象分开缓存，类型不一致视为未命中，不存在的键对两者都命中
+func (c *lruCache) get(key string, obj bool) (value string, missing bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return "", false, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !time.Now().Before(entry.expireAt) {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+		return "", false, false
+	}
+	if !entry.missing && entry.obj != obj {
+		return "", false, false
+	}
+	c.ll.MoveToFront(elem)
+	return entry.value, entry.missing, true
+}
+
+// add 结束读取并缓存读到的结果，读取期间键被失效或 timeout 非正数时不缓存
+func (c *lruCache) add(key string, entry lruEntry, timeout time.Duration, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.releaseLocked(key, generation) || timeout <= 0 {
+		return
+	}
+	entry.key = key
+	entry.expireAt = time.Now().Add(timeout)
+	if elem, ok := c.items[key]; ok {
+		elem.Value = &entry
+		c.ll.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry)
+	for c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if loading, ok := c.loading[key]; ok {
+		loading.generation++
+	}
+	if elem, ok := c.items[key]; ok {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+	}
+}
@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCacheStore_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStore()
+	defer backend.Close()
+	bus := NewMemoryInvalidationBus()
+	first := NewCacheStore(backend, WithInvalidationBus(bus))
+	defer first.Close()
+	second := NewCacheStore(backend, WithInvalidationBus(bus))
+	defer second.Close()
+
+	assert.NoError(t, first.Set(ctx, "token", "10001", time.Minute))
+	val, _ := second.Get(ctx, "token")
+	assert.Equal(t, "10001", val)
+
+	// 直接修改底层存储时读到缓存
+	assert.NoError(t, backend.Set(ctx, "token", "10002", time.Minute))
+	val, _ = second.Get(ctx, "token")
+	assert.Equal(t, "10001", val)
+
+	// 其他实例的删除通过总线失效
+	assert.NoError(t, first.Delete(ctx, "token"))
+	val, _ = second.Get(ctx, "token")
+	assert.Equal(t, "", val)
+
+	sess := &satoken.Session{Id: "session", LoginId: "10001"}
+	assert.NoError(t, first.SetObj(ctx, "session", sess, time.Minute))
+	var ret satoken.Session
+	assert.NoError(t, second.GetObj(ctx, "session", &ret))
+	sess.LoginId = "10002"
+	assert.NoError(t, first.UpdateObj(ctx, "session", sess))
+	assert.NoError(t, second.GetObj(ctx, "session", &ret))
+	assert.Equal(t, "10002", ret.LoginId)
+}
+
+func TestCacheStore_Eviction(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStore()
+	defer backend.Close()
+	s := NewCacheStore(backend, WithCacheSize(2), WithCacheTimeout(50*time.Millisecond))
+	defer s.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, backend.Set(ctx, key, key, time.Minute))
+		_, _ = s.Get(ctx, key)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, backend.Set(ctx, key, key+"2", time.Minute))
+	}
+	// 最久未使用的 a 被淘汰
+	val, _ := s.Get(ctx, "a")
+	assert.Equal(t, "a2", val)
+	val, _ = s.Get(ctx, "c")
+	assert.Equal(t, "c", val)
+
+	time.Sleep(60 * time.Millisecond)
+	val, _ = s.Get(ctx, "c")
+	assert.Equal(t, "c2", val)
+}
+
+func TestCacheStore_Batch(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStore()
+	defer backend.Close()
+	s := NewCacheStore(backend)
+	defer s.Close()
+
+	assert.NoError(t, s.Set(ctx, "token", "10001", time.Minute))
+	_, _ = s.Get(ctx, "token")
+	batch := s.Batch(ctx)
+	assert.NoError(t, batch.Delete(ctx, "token"))
+	val, _ := s.Get(ctx, "token")
+	assert.Equal(t, "10001", val)
+	assert.NoError(t, batch.Commit(ctx))
+	val, _ = s.Get(ctx, "token")
+	assert.Equal(t, "", val)
+
+	// 被包装的存储不支持批量时直接写入，不能原子校验
+	assert.True(t, s.IsAtomicBatch())
+	direct := NewCacheStore(plainStore{backend})
+	defer direct.Close()
+	assert.False(t, direct.IsAtomicBatch())
+}
+
+// plainStore 只实现 satoken.TokenStore
+type plainStore struct {
+	satoken.TokenStore
+}
+
+func TestCacheStore_Miss(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStore()
+	defer backend.Close()
+	s := NewCacheStore(backend, WithCacheMissTimeout(30*time.Millisecond))
+	defer s.Close()
+
+	// 不存在的键在短时间内不再读取底层存储
+	val, _ := s.Get(ctx, "token")
+	assert.Equal(t, "", val)
+	assert.NoError(t, backend.Set(ctx, "token", "10001", time.Minute))
+	val, _ = s.Get(ctx, "token")
+	assert.Equal(t, "", val)
+	var ret satoken.Session
+	assert.ErrorIs(t, s.GetObj(ctx, "token", &ret), satoken.ErrObjectNotExist)
+	time.Sleep(40 * time.Millisecond)
+	val, _ = s.Get(ctx, "token")
+	assert.Equal(t, "10001", val)
+
+	// 写入时失效
+	assert.ErrorIs(t, s.GetObj(ctx, "session", &ret), satoken.ErrObjectNotExist)
+	assert.NoError(t, s.SetObj(ctx, "session", satoken.Session{Id: "session"}, time.Minute))
+	assert.NoError(t, s.GetObj(ctx, "session", &ret))
+	assert.Equal(t, "session", ret.Id)
+}
+
+func TestCacheStore_Bypass(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryStore()
+	defer backend.Close()
+	bus := &countingBus{MemoryInvalidationBus: NewMemoryInvalidationBus()}
+	s := NewCacheStore(backend, WithInvalidationBus(bus), WithCacheBypass(satoken.NewDefaultManager().IsHotPathKey))
+	defer s.Close()
+
+	key := "satoken:login:last-active:token"
+	assert.NoError(t, s.Set(ctx, key, "1", time.Minute))
+	assert.NoError(t, s.Update(ctx, key, "2"))
+	assert.Equal(t, 0, bus.published)
+	assert.NoError(t, backend.Update(ctx, key, "3"))
+	val, _ := s.Get(ctx, key)
+	assert.Equal(t, "3", val)
+
+	assert.NoError(t, s.Set(ctx, "satoken:login:token:token", "10001", time.Minute))
+	assert.Equal(t, 1, bus.published)
+}
+
+func TestLruCache_Generation(t *testing.T) {
+	c := newLruCache(10)
+
+	// 其他键失效不影响读取中的键
+	generation := c.load("a")
+	c.remove("b")
+	c.add("a", lruEntry{value: "1"}, time.Minute, generation)
+	val, _, ok := c.get("a", false)
+	assert.True(t, ok)
+	assert.Equal(t, "1", val)
+
+	// 读取期间键被失效时不缓存旧值
+	generation = c.load("a")
+	c.remove("a")
+	c.add("a", lruEntry{value: "2"}, time.Minute, generation)
+	_, _, ok = c.get("a", false)
+	assert.False(t, ok)
+	assert.Empty(t, c.loading)
+}
+
+type countingBus struct {
+	*MemoryInvalidationBus
+	published int
+}
+
+func (b *countingBus) Publish(ctx context.Context, key string) error {
+	b.published++
+	return b.MemoryInvalidationBus.Publish(ctx, key)
+}
@@ -0,0 +1,597 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/cloudwego/hertz/pkg/common/hlog"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	_ satoken.TokenStore     = &FileStore{}
+	_ satoken.Scanner        = &FileStore{}
+	_ satoken.VersionedStore = &FileStore{}
+	_ satoken.Batcher        = &FileStore{}
+	_ satoken.AtomicBatcher  = &FileStore{}
+	_ satoken.ObjTaker       = &FileStore{}
+	_ satoken.Counter        = &FileStore{}
+
+	errFileStoreClosed = errors.New("store: file store is closed")
+	errFileStoreLocked = errors.New("store: file store is opened by another process")
+	errFileRecord      = errors.New("store: invalid file record")
+)
+
+// FsyncPolicy when the FileStore flushes the appended records to the disk
+type FsyncPolicy int
+
+const (
+	// FsyncEverySecond fsync once per second, a crash loses at most the writes of the last second
+	FsyncEverySecond FsyncPolicy = iota
+	// FsyncAlways fsync before each write returns, including the last active time refreshes written when
+	// satoken.Config.ActiveTimeout is enabled, at most once per token every tenth of it or every minute
+	FsyncAlways
+	// FsyncNever leave the flushing to the operating system
+	FsyncNever
+)
+
+const (
+	// 记录头：crc32 和数据长度
+	fileRecordHeader = 8
+	fileEntryPut     = 0
+	fileEntryDelete  = 1
+	// 日志超过该大小且达到上次压缩后的两倍时自动压缩
+	fileCompactMinSize = 1 << 20
+)
+
+// 日志记录中一个键的最终状态，item 为 nil 表示删除
+type fileEntry struct {
+	key  string
+	item *memoryItem
+}
+
+// NewFileStore open the file store at path, the log is replayed into memory and a torn tail left by a crash is truncated.
+// The file path+".lock" is locked exclusively until Close, opening a log in use by another store returns an error
+func NewFileStore(path string, opts ...Option) (*FileStore, error) {
+	options := NewOptions(opts...)
+	// 压缩时日志被替换，锁加在旁边的锁文件上
+	lock, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFile(lock); err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	store := &FileStore{
+		mem:    NewMemoryStoreWithInterval(time.Minute, opts...),
+		path:   path,
+		policy: options.fsyncPolicy,
+		lock:   lock,
+		stop:   make(chan struct{}),
+	}
+	if err = store.load(); err != nil {
+		_ = store.mem.Close()
+		_ = lock.Close()
+		return nil, err
+	}
+	if store.policy == FsyncEverySecond {
+		store.loop(time.Second, store.flush)
+	}
+	if options.compactInterval > 0 {
+		store.loop(options.compactInterval, store.compactIfNeeded)
+	}
+	return store, nil
+}
+
+// FileStore persistent token store for single instance deployments, the keys live in memory
+// with the same semantics as the redis store, every write is appended to a log replayed on restart.
+// The log is compacted by rewriting the live keys into a temporary file renamed over it
+type FileStore struct {
+	// mem 内存中的键，写入经 commit 追加到日志，不能直接修改
+	mem    *MemoryStore
+	path   string
+	policy FsyncPolicy
+	// lock 持有排他锁的锁文件，避免多个进程写入同一日志
+	lock *os.File
+	// fileMu 保护 file 和 dirty，写入时在 MemoryStore 锁内获取
+	fileMu sync.Mutex
+	file   *os.File
+	dirty  bool
+	// size 日志大小，baseSize 上次压缩后的大小，在 MemoryStore 锁内修改
+	size     int64
+	baseSize int64
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// Close flush the log and stop the background tasks
+func (s *FileStore) Close() error {
+	var err error
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+		s.mem.mu.Lock()
+		s.fileMu.Lock()
+		if err = s.file.Sync(); err == nil {
+			err = s.file.Close()
+		} else {
+			_ = s.file.Close()
+		}
+		s.file = nil
+		s.fileMu.Unlock()
+		s.mem.mu.Unlock()
+		_ = s.mem.Close()
+		_ = s.lock.Close()
+	})
+	return err
+}
+
+func (s *FileStore) loop(interval time.Duration, fn func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// 重放日志，遇到不完整或校验失败的记录时截断之后的内容
+func (s *FileStore) load() error {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	now := time.Now()
+	reader := bufio.NewReader(file)
+	header := make([]byte, fileRecordHeader)
+	var offset int64
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			break
+		}
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		if offset+fileRecordHeader+length > stat.Size() {
+			break
+		}
+		payload := make([]byte, length)
+		if _, err = io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header) {
+			break
+		}
+		entries, err := decodeFileEntries(payload)
+		if err != nil {
+			break
+		}
+		for _, entry := range entries {
+			if entry.item == nil || entry.item.expired(now) {
+				delete(s.mem.items, entry.key)
+			} else {
+				s.mem.items[entry.key] = entry.item
+			}
+		}
+		offset += fileRecordHeader + length
+	}
+	if offset < stat.Size() {
+		hlog.Warnf("satoken: truncate the torn tail of %s at %d, size %d", s.path, offset, stat.Size())
+		if err = file.Truncate(offset); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	s.file = file
+	s.size, s.baseSize = offset, offset
+	return nil
+}
+
+func encodeFileRecord(entries []fileEntry) []byte {
+	record := make([]byte, fileRecordHeader)
+	record = binary.AppendUvarint(record, uint64(len(entries)))
+	for _, entry := range entries {
+		if entry.item == nil {
+			record = append(record, fileEntryDelete)
+			record = appendFileString(record, entry.key)
+			continue
+		}
+		record = append(record, fileEntryPut)
+		record = appendFileString(record, entry.key)
+		var expireAt int64
+		if !entry.item.expireAt.IsZero() {
+			expireAt = entry.item.expireAt.UnixNano()
+		}
+		record = binary.AppendVarint(record, expireAt)
+		record = appendFileString(record, entry.item.value)
+	}
+	binary.LittleEndian.PutUint32(record[4:], uint32(len(record)-fileRecordHeader))
+	binary.LittleEndian.PutUint32(record, crc32.ChecksumIEEE(record[fileRecordHeader:]))
+	return record
+}
+
+func appendFileString(data []byte, value string) []byte {
+	data = binary.AppendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+func decodeFileEntries(payload []byte) ([]fileEntry, error) {
+	count, n := binary.Uvarint(payload)
+	if n <= 0 || count > uint64(len(payload)) {
+		return nil, errFileRecord
+	}
+	payload = payload[n:]
+	entries := make([]fileEntry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		if len(payload) == 0 {
+			return nil, errFileRecord
+		}
+		kind := payload[0]
+		key, rest, err := readFileString(payload[1:])
+		if err != nil {
+			return nil, err
+		}
+		payload = rest
+		if kind == fileEntryDelete {
+			entries = append(entries, fileEntry{key: key})
+			continue
+		}
+		expireAt, n := binary.Varint(payload)
+		if n <= 0 {
+			return nil, errFileRecord
+		}
+		value, rest, err := readFileString(payload[n:])
+		if err != nil {
+			return nil, err
+		}
+		payload = rest
+		item := &memoryItem{value: value}
+		if expireAt != 0 {
+			item.expireAt = time.Unix(0, expireAt)
+		}
+		entries = append(entries, fileEntry{key: key, item: item})
+	}
+	return entries, nil
+}
+
+func readFileString(data []byte) (string, []byte, error) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length > uint64(len(data)-n) {
+		return "", nil, errFileRecord
+	}
+	end := n + int(length)
+	return string(data[n:end]), data[end:], nil
+}
+
+// 追加一条记录，写入失败时截断不完整的记录，调用方持有 MemoryStore 锁
+func (s *FileStore) append(record []byte) error {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.file == nil {
+		return errFileStoreClosed
+	}
+	_, err := s.file.Write(record)
+	if err == nil && s.policy == FsyncAlways {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		_ = s.file.Truncate(s.size)
+		return err
+	}
+	s.size += int64(len(record))
+	s.dirty = s.policy != FsyncAlways
+	return nil
+}
+
+// 在内存中执行写入并作为一条记录追加到日志，追加失败时回滚内存，调用方持有 MemoryStore 锁
+func (s *FileStore) commit(ops []memoryOp, now time.Time) error {
+	backup := make(map[string]*memoryItem)
+	keys := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if _, ok := backup[op.key]; !ok {
+			var item *memoryItem
+			if origin := s.mem.getItem(op.key, now); origin != nil {
+				clone := *origin
+				item = &clone
+			}
+			backup[op.key] = item
+			keys = append(keys, op.key)
+		}
+		op.apply(now)
+	}
+	entries := make([]fileEntry, 0, len(keys))
+	for _, key := range keys {
+		item := s.mem.getItem(key, now)
+		// 不存在的键未被修改
+		if item == nil && backup[key] == nil {
+			continue
+		}
+		entries = append(entries, fileEntry{key: key, item: item})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := s.append(encodeFileRecord(entries)); err != nil {
+		for key, item := range backup {
+			if item == nil {
+				delete(s.mem.items, key)
+			} else {
+				s.mem.items[key] = item
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) write(op memoryOp) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	return s.commit([]memoryOp{op}, time.Now())
+}
+
+func (s *FileStore) Get(ctx context.Context, key string) (string, error) {
+	return s.mem.Get(ctx, key)
+}
+
+func (s *FileStore) Set(_ context.Context, key string, value string, exp time.Duration) error {
+	return s.write(memoryOp{key, func(now time.Time) {
+		s.mem.set(key, value, exp, now)
+	}})
+}
+
+func (s *FileStore) Update(_ context.Context, key string, val string) error {
+	return s.write(memoryOp{key, func(now time.Time) {
+		s.mem.update(key, val, now)
+	}})
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	return s.write(memoryOp{key, func(time.Time) {
+		delete(s.mem.items, key)
+	}})
+}
+
+// Incr increase the integer value under the lock
+func (s *FileStore) Incr(_ context.Context, key string, exp time.Duration) (int64, error) {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	var value int64
+	var err error
+	if commitErr := s.commit([]memoryOp{{key, func(now time.Time) {
+		value, err = s.mem.incr(key, exp, now)
+	}}}, time.Now()); commitErr != nil {
+		return 0, commitErr
+	}
+	return value, err
+}
+
+func (s *FileStore) GetTimeout(ctx context.Context, key string) (time.Duration, error) {
+	return s.mem.GetTimeout(ctx, key)
+}
+
+func (s *FileStore) UpdateTimeout(_ context.Context, key string, exp time.Duration) error {
+	return s.write(memoryOp{key, func(now time.Time) {
+		s.mem.expire(key, exp, now)
+	}})
+}
+
+func (s *FileStore) GetObj(ctx context.Context, key string, ret any) error {
+	return s.mem.GetObj(ctx, key, ret)
+}
+
+func (s *FileStore) SetObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	data, err := s.mem.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, key, data, exp)
+}
+
+func (s *FileStore) UpdateObj(ctx context.Context, key string, val any) error {
+	data, err := s.mem.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return s.Update(ctx, key, data)
+}
+
+// UpdateObjWithVersion compare and update the object under the lock
+func (s *FileStore) UpdateObjWithVersion(_ context.Context, key string, val any, version int64) error {
+	data, err := s.mem.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	now := time.Now()
+	item := s.mem.getItem(key, now)
+	if item == nil {
+		return nil
+	}
+	if err = s.mem.codec.checkVersion(item.value, version); err != nil {
+		return err
+	}
+	return s.commit([]memoryOp{{key, func(now time.Time) {
+		s.mem.update(key, data, now)
+	}}}, now)
+}
+
+// CreateObj check and set the object under the lock
+func (s *FileStore) CreateObj(_ context.Context, key string, val any, exp time.Duration) error {
+	data, err := s.mem.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	now := time.Now()
+	if s.mem.getItem(key, now) != nil {
+		return satoken.ErrSessionConflict
+	}
+	return s.commit([]memoryOp{{key, func(now time.Time) {
+		s.mem.set(key, data, exp, now)
+	}}}, now)
+}
+
+// GetDelObj read and delete the object under the lock
+func (s *FileStore) GetDelObj(_ context.Context, key string, ret any) error {
+	s.mem.mu.Lock()
+	now := time.Now()
+	item := s.mem.getItem(key, now)
+	if item == nil {
+		s.mem.mu.Unlock()
+		return satoken.ErrObjectNotExist
+	}
+	err := s.commit([]memoryOp{{key, func(time.Time) {
+		delete(s.mem.items, key)
+	}}}, now)
+	s.mem.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.mem.codec.unmarshal(item.value, ret)
+}
+
+func (s *FileStore) DeleteObj(ctx context.Context, key string) error {
+	return s.Delete(ctx, key)
+}
+
+func (s *FileStore) GetObjTimeout(ctx context.Context, key string) (time.Duration, error) {
+	return s.mem.GetObjTimeout(ctx, key)
+}
+
+func (s *FileStore) UpdateObjTimeout(ctx context.Context, key string, exp time.Duration) error {
+	return s.UpdateTimeout(ctx, key, exp)
+}
+
+// Scan call fn with each unexpired key starting with prefix
+func (s *FileStore) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	return s.mem.Scan(ctx, prefix, fn)
+}
+
+// Batch start a batch appended to the log as one record, a crash never leaves part of it
+func (s *FileStore) Batch(_ context.Context) satoken.Batch {
+	return newMemoryBatch(s.mem, s.commit)
+}
+
+// IsAtomicBatch the batches are checked and applied under the store lock
+func (s *FileStore) IsAtomicBatch() bool {
+	return true
+}
+
+func (s *FileStore) flush() {
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.file == nil || !s.dirty {
+		return
+	}
+	if err := s.file.Sync(); err != nil {
+		hlog.Warnf("satoken: fsync %s error: %v", s.path, err)
+		return
+	}
+	s.dirty = false
+}
+
+func (s *FileStore) compactIfNeeded() {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	if s.size < fileCompactMinSize || s.size < 2*s.baseSize {
+		return
+	}
+	if err := s.compact(); err != nil {
+		hlog.Warnf("satoken: compact %s error: %v", s.path, err)
+	}
+}
+
+// Compact rewrite the log with only the live keys
+func (s *FileStore) Compact() error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	return s.compact()
+}
+
+// 将存活的键写入临时文件并刷盘，再重命名覆盖日志，崩溃时保留旧日志或新日志之一
+func (s *FileStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	size, err := s.writeSnapshot(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	s.fileMu.Lock()
+	defer s.fileMu.Unlock()
+	if s.file == nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return errFileStoreClosed
+	}
+	if err = os.Rename(tmpPath, s.path); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = syncDir(filepath.Dir(s.path)); err != nil {
+		hlog.Warnf("satoken: fsync the directory of %s error: %v", s.path, err)
+	}
+	// 重命名后临时文件句柄即指向新日志
+	_ = s.file.Close()
+	s.file = tmp
+	s.dirty = false
+	s.size, s.baseSize = size, size
+	return nil
+}
+
+func (s *FileStore) writeSnapshot(file *os.File) (int64, error) {
+	now := time.Now()
+	writer := bufio.NewWriter(file)
+	var size int64
+	for key, item := range s.mem.items {
+		if item.expired(now) {
+			continue
+		}
+		n, err := writer.Write(encodeFileRecord([]fileEntry{{key: key, item: item}}))
+		if err != nil {
+			return 0, err
+		}
+		size += int64(n)
+	}
+	return size, writer.Flush()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package store
+
+import "os"
+
+// 不支持文件锁的平台不检查其他进程
+func lockFile(*os.File) error {
+	return nil
+}
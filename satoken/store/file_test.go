@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestFileStore(t *testing.T, path string) *FileStore {
+	s, err := NewFileStore(path, WithFsyncPolicy(FsyncAlways))
+	assert.NoError(t, err)
+	return s
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "satoken.log")
+	s := openTestFileStore(t, path)
+
+	assert.NoError(t, s.Set(ctx, "token", "10001", time.Minute))
+	assert.NoError(t, s.Set(ctx, "forever", "v", 0))
+	assert.NoError(t, s.Update(ctx, "forever", "v2"))
+	assert.NoError(t, s.Set(ctx, "deleted", "v", time.Minute))
+	assert.NoError(t, s.Delete(ctx, "deleted"))
+	assert.NoError(t, s.Set(ctx, "short", "v", 20*time.Millisecond))
+	assert.NoError(t, s.SetObj(ctx, "session", &satoken.Session{Id: "session", LoginId: "10001"}, time.Minute))
+	assert.NoError(t, s.Close())
+
+	time.Sleep(30 * time.Millisecond)
+	s = openTestFileStore(t, path)
+	defer s.Close()
+	val, _ := s.Get(ctx, "token")
+	assert.Equal(t, "10001", val)
+	ttl, _ := s.GetTimeout(ctx, "token")
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+	val, _ = s.Get(ctx, "forever")
+	assert.Equal(t, "v2", val)
+	ttl, _ = s.GetTimeout(ctx, "forever")
+	assert.Equal(t, ttlNoExpire, ttl)
+	val, _ = s.Get(ctx, "deleted")
+	assert.Equal(t, "", val)
+	val, _ = s.Get(ctx, "short")
+	assert.Equal(t, "", val)
+	var sess satoken.Session
+	assert.NoError(t, s.GetObj(ctx, "session", &sess))
+	assert.Equal(t, "10001", sess.LoginId)
+}
+
+func TestFileStore_TornTail(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "satoken.log")
+	s := openTestFileStore(t, path)
+	assert.NoError(t, s.Set(ctx, "token", "10001", time.Minute))
+	assert.NoError(t, s.Close())
+	stat, _ := os.Stat(path)
+
+	// 模拟崩溃时写入一半的记录
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	_, _ = file.Write(encodeFileRecord([]fileEntry{{key: "torn", item: &memoryItem{value: "v"}}})[:10])
+	_ = file.Close()
+
+	s = openTestFileStore(t, path)
+	val, _ := s.Get(ctx, "token")
+	assert.Equal(t, "10001", val)
+	truncated, _ := os.Stat(path)
+	assert.Equal(t, stat.Size(), truncated.Size())
+	assert.NoError(t, s.Set(ctx, "next", "v", time.Minute))
+	assert.NoError(t, s.Close())
+
+	s = openTestFileStore(t, path)
+	defer s.Close()
+	val, _ = s.Get(ctx, "next")
+	assert.Equal(t, "v", val)
+}
+
+func TestFileStore_CompactAndBatch(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "satoken.log")
+	s := openTestFileStore(t, path)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, s.Set(ctx, "token", "10001", time.Minute))
+	}
+	batch := s.Batch(ctx)
+	assert.NoError(t, batch.Set(ctx, "session", "v", time.Minute))
+	assert.NoError(t, batch.Delete(ctx, "token"))
+	assert.NoError(t, batch.Commit(ctx))
+	before, _ := os.Stat(path)
+
+	assert.NoError(t, s.Compact())
+	after, _ := os.Stat(path)
+	assert.Less(t, after.Size(), before.Size())
+	assert.NoError(t, s.Set(ctx, "next", "v", time.Minute))
+	assert.NoError(t, s.Close())
+
+	s = openTestFileStore(t, path)
+	defer s.Close()
+	val, _ := s.Get(ctx, "token")
+	assert.Equal(t, "", val)
+	val, _ = s.Get(ctx, "session")
+	assert.Equal(t, "v", val)
+	val, _ = s.Get(ctx, "next")
+	assert.Equal(t, "v", val)
+}
+
+func TestFileStore_Lock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "satoken.log")
+	s := openTestFileStore(t, path)
+
+	// 日志只能被一个存储打开，压缩后仍然锁定
+	_, err := NewFileStore(path)
+	assert.ErrorIs(t, err, errFileStoreLocked)
+	assert.NoError(t, s.Compact())
+	_, err = NewFileStore(path)
+	assert.ErrorIs(t, err, errFileStoreLocked)
+
+	assert.NoError(t, s.Close())
+	s = openTestFileStore(t, path)
+	assert.NoError(t, s.Close())
+}
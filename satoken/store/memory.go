@@ -0,0 +1,314 @@
+package store
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	_ satoken.TokenStore     = &MemoryStore{}
+	_ satoken.Scanner        = &MemoryStore{}
+	_ satoken.VersionedStore = &MemoryStore{}
+	_ satoken.ObjTaker       = &MemoryStore{}
+	_ satoken.Counter        = &MemoryStore{}
+)
+
+const (
+	// ttlNoExpire mirrors the redis TTL reply for keys without an expiration
+	ttlNoExpire = time.Duration(-1)
+	// ttlNotExist mirrors the redis TTL reply for missing keys
+	ttlNotExist = time.Duration(-2)
+)
+
+// NewMemoryStore create an instance of a memory store,
+// expired keys are evicted every minute
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	return NewMemoryStoreWithInterval(time.Minute, opts...)
+}
+
+// NewMemoryStoreWithInterval create an instance of a memory store,
+// expired keys are evicted at the given interval
+func NewMemoryStoreWithInterval(interval time.Duration, opts ...Option) *MemoryStore {
+	store := &MemoryStore{
+		items: make(map[string]*memoryItem),
+		done:  make(chan struct{}),
+		codec: newCodec(NewOptions(opts...)),
+	}
+	if interval > 0 {
+		go store.janitor(interval)
+	}
+	return store
+}
+
+type memoryItem struct {
+	value    string
+	expireAt time.Time
+}
+
+func (i *memoryItem) expired(now time.Time) bool {
+	return !i.expireAt.IsZero() && !now.Before(i.expireAt)
+}
+
+func (i *memoryItem) ttl(now time.Time) time.Duration {
+	if i.expireAt.IsZero() {
+		return ttlNoExpire
+	}
+	return i.expireAt.Sub(now)
+}
+
+// MemoryStore in-process token store, keys expire with the same semantics as the redis store
+type MemoryStore struct {
+	mu    sync.RWMutex
+	items map[string]*memoryItem
+	done  chan struct{}
+	once  sync.Once
+	codec *codec
+}
+
+// Close stop the background eviction
+func (s *MemoryStore) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	return nil
+}
+
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.deleteExpired()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *MemoryStore) deleteExpired() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, item := range s.items {
+		if item.expired(now) {
+			delete(s.items, key)
+		}
+	}
+}
+
+// getItem return the live item of key, the caller must hold the lock
+func (s *MemoryStore) getItem(key string, now time.Time) *memoryItem {
+	item, ok := s.items[key]
+	if !ok || item.expired(now) {
+		return nil
+	}
+	return item
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if item := s.getItem(key, time.Now()); item != nil {
+		return item.value, nil
+	}
+	return "", nil
+}
+
+// set write the item, the caller must hold the lock
+func (s *MemoryStore) set(key string, value string, exp time.Duration, now time.Time) {
+	item := &memoryItem{value: value}
+	if exp > 0 {
+		item.expireAt = now.Add(exp)
+	}
+	s.items[key] = item
+}
+
+// update replace the value of the live item keeping its expiration, the caller must hold the lock
+func (s *MemoryStore) update(key string, value string, now time.Time) {
+	if item := s.getItem(key, now); item != nil {
+		item.value = value
+	}
+}
+
+// expire reset the expiration of the live item, the caller must hold the lock
+func (s *MemoryStore) expire(key string, exp time.Duration, now time.Time) {
+	item := s.getItem(key, now)
+	if item == nil {
+		return
+	}
+	// same as redis EXPIRE, a non-positive timeout deletes the key
+	if exp <= 0 {
+		delete(s.items, key)
+		return
+	}
+	item.expireAt = now.Add(exp)
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value string, exp time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value, exp, time.Now())
+	return nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, key string, val string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.update(key, val, time.Now())
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// incr increase the integer value and reset the expiration, the caller must hold the lock
+func (s *MemoryStore) incr(key string, exp time.Duration, now time.Time) (int64, error) {
+	var value int64
+	if item := s.getItem(key, now); item != nil {
+		var err error
+		if value, err = strconv.ParseInt(item.value, 10, 64); err != nil {
+			return 0, err
+		}
+	}
+	value++
+	s.set(key, strconv.FormatInt(value, 10), exp, now)
+	return value, nil
+}
+
+// Incr increase the integer value under the lock
+func (s *MemoryStore) Incr(_ context.Context, key string, exp time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.incr(key, exp, time.Now())
+}
+
+func (s *MemoryStore) GetTimeout(_ context.Context, key string) (time.Duration, error) {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if item := s.getItem(key, now); item != nil {
+		return item.ttl(now), nil
+	}
+	return ttlNotExist, nil
+}
+
+func (s *MemoryStore) UpdateTimeout(_ context.Context, key string, exp time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key, exp, time.Now())
+	return nil
+}
+
+func (s *MemoryStore) GetObj(ctx context.Context, key string, ret any) error {
+	val, err := s.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if val == "" {
+		return satoken.ErrObjectNotExist
+	}
+	return s.codec.unmarshal(val, ret)
+}
+
+func (s *MemoryStore) SetObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	data, err := s.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return s.Set(ctx, key, data, exp)
+}
+
+func (s *MemoryStore) UpdateObj(ctx context.Context, key string, val any) error {
+	data, err := s.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return s.Update(ctx, key, data)
+}
+
+// UpdateObjWithVersion compare and update the object under the lock
+func (s *MemoryStore) UpdateObjWithVersion(_ context.Context, key string, val any, version int64) error {
+	data, err := s.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item := s.getItem(key, time.Now())
+	if item == nil {
+		return nil
+	}
+	if err = s.codec.checkVersion(item.value, version); err != nil {
+		return err
+	}
+	item.value = data
+	return nil
+}
+
+// GetDelObj read and delete the object under the lock
+func (s *MemoryStore) GetDelObj(_ context.Context, key string, ret any) error {
+	s.mu.Lock()
+	item := s.getItem(key, time.Now())
+	delete(s.items, key)
+	s.mu.Unlock()
+	if item == nil {
+		return satoken.ErrObjectNotExist
+	}
+	return s.codec.unmarshal(item.value, ret)
+}
+
+// CreateObj check and set the object under the lock
+func (s *MemoryStore) CreateObj(_ context.Context, key string, val any, exp time.Duration) error {
+	data, err := s.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.getItem(key, now) != nil {
+		return satoken.ErrSessionConflict
+	}
+	s.set(key, data, exp, now)
+	return nil
+}
+
+func (s *MemoryStore) DeleteObj(ctx context.Context, key string) error {
+	return s.Delete(ctx, key)
+}
+
+func (s *MemoryStore) GetObjTimeout(ctx context.Context, key string) (time.Duration, error) {
+	return s.GetTimeout(ctx, key)
+}
+
+func (s *MemoryStore) UpdateObjTimeout(ctx context.Context, key string, exp time.Duration) error {
+	return s.UpdateTimeout(ctx, key, exp)
+}
+
+// Scan call fn with each unexpired key starting with prefix
+func (s *MemoryStore) Scan(_ context.Context, prefix string, fn func(key string) bool) error {
+	now := time.Now()
+	s.mu.RLock()
+	keys := make([]string, 0)
+	for key, item := range s.items {
+		if strings.HasPrefix(key, prefix) && !item.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.RUnlock()
+	for _, key := range keys {
+		if !fn(key) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"time"
+)
+
+var (
+	_ satoken.Batcher       = &MemoryStore{}
+	_ satoken.AtomicBatcher = &MemoryStore{}
+)
+
+// Batch start a batch applied under the store lock
+func (s *MemoryStore) Batch(_ context.Context) satoken.Batch {
+	return newMemoryBatch(s, applyMemoryOps)
+}
+
+// IsAtomicBatch the batches are checked and applied under the store lock
+func (s *MemoryStore) IsAtomicBatch() bool {
+	return true
+}
+
+// 批次中对一个键的写入
+type memoryOp struct {
+	key   string
+	apply func(now time.Time)
+}
+
+func applyMemoryOps(ops []memoryOp, now time.Time) error {
+	for _, op := range ops {
+		op.apply(now)
+	}
+	return nil
+}
+
+func newMemoryBatch(s *MemoryStore, commit func(ops []memoryOp, now time.Time) error) *memoryBatch {
+	return &memoryBatch{
+		MemoryStore: s,
+		versions:    make(map[string]int64),
+		written:     make(map[string]bool),
+		expects:     make(map[string]string),
+		commit:      commit,
+	}
+}
+
+// memoryBatch queue the writes, reads are served by the embedded store
+type memoryBatch struct {
+	*MemoryStore
+	ops []memoryOp
+	// 每个键只校验批次中首次写入时的版本
+	versions map[string]int64
+	// 批次中重新写入或删除的键不再校验版本
+	written map[string]bool
+	// 提交时要求的键值
+	expects map[string]string
+	// commit 在存储锁内执行写入
+	commit func(ops []memoryOp, now time.Time) error
+}
+
+func (b *memoryBatch) Set(_ context.Context, key string, value string, exp time.Duration) error {
+	b.written[key] = true
+	b.ops = append(b.ops, memoryOp{key, func(now time.Time) {
+		b.set(key, value, exp, now)
+	}})
+	return nil
+}
+
+func (b *memoryBatch) Update(_ context.Context, key string, val string) error {
+	b.ops = append(b.ops, memoryOp{key, func(now time.Time) {
+		b.update(key, val, now)
+	}})
+	return nil
+}
+
+func (b *memoryBatch) Delete(_ context.Context, key string) error {
+	b.written[key] = true
+	b.ops = append(b.ops, memoryOp{key, func(time.Time) {
+		delete(b.items, key)
+	}})
+	return nil
+}
+
+func (b *memoryBatch) UpdateTimeout(_ context.Context, key string, exp time.Duration) error {
+	b.ops = append(b.ops, memoryOp{key, func(now time.Time) {
+		b.expire(key, exp, now)
+	}})
+	return nil
+}
+
+func (b *memoryBatch) SetObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	data, err := b.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return b.Set(ctx, key, data, exp)
+}
+
+func (b *memoryBatch) UpdateObj(ctx context.Context, key string, val any) error {
+	data, err := b.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return b.Update(ctx, key, data)
+}
+
+func (b *memoryBatch) DeleteObj(ctx context.Context, key string) error {
+	return b.Delete(ctx, key)
+}
+
+func (b *memoryBatch) UpdateObjTimeout(ctx context.Context, key string, exp time.Duration) error {
+	return b.UpdateTimeout(ctx, key, exp)
+}
+
+func (b *memoryBatch) UpdateObjWithVersion(ctx context.Context, key string, val any, version int64) error {
+	if _, ok := b.versions[key]; !ok && !b.written[key] {
+		b.versions[key] = version
+	}
+	return b.UpdateObj(ctx, key, val)
+}
+
+// CreateObj 批次中已写入或删除的键不再要求不存在
+func (b *memoryBatch) CreateObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	if !b.written[key] {
+		b.expects[key] = ""
+	}
+	return b.SetObj(ctx, key, val, exp)
+}
+
+func (b *memoryBatch) Expect(_ context.Context, key string, value string) error {
+	b.expects[key] = value
+	return nil
+}
+
+func (b *memoryBatch) Commit(_ context.Context) error {
+	now := time.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, value := range b.expects {
+		var stored string
+		if item := b.getItem(key, now); item != nil {
+			stored = item.value
+		}
+		if stored != value {
+			return satoken.ErrSessionConflict
+		}
+	}
+	for key, version := range b.versions {
+		if item := b.getItem(key, now); item != nil {
+			if err := b.codec.checkVersion(item.value, version); err != nil {
+				return err
+			}
+		}
+	}
+	if err := b.commit(b.ops, now); err != nil {
+		return err
+	}
+	b.ops = nil
+	return nil
+}
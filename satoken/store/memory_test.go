@@ -0,0 +1,234 @@
+package store
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Value(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	val, err := s.Get(ctx, "k")
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+
+	assert.NoError(t, s.Set(ctx, "k", "v", time.Minute))
+	val, _ = s.Get(ctx, "k")
+	assert.Equal(t, "v", val)
+
+	assert.NoError(t, s.Update(ctx, "k", "v2"))
+	val, _ = s.Get(ctx, "k")
+	assert.Equal(t, "v2", val)
+	ttl, _ := s.GetTimeout(ctx, "k")
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	// update never creates a key
+	assert.NoError(t, s.Update(ctx, "missing", "v"))
+	ttl, _ = s.GetTimeout(ctx, "missing")
+	assert.Equal(t, ttlNotExist, ttl)
+
+	assert.NoError(t, s.Delete(ctx, "k"))
+	val, _ = s.Get(ctx, "k")
+	assert.Equal(t, "", val)
+}
+
+func TestMemoryStore_Incr(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		value, err := s.Incr(ctx, "counter", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+	ttl, _ := s.GetTimeout(ctx, "counter")
+	assert.True(t, ttl > 0 && ttl <= time.Minute)
+
+	assert.NoError(t, s.Set(ctx, "text", "v", time.Minute))
+	_, err := s.Incr(ctx, "text", time.Minute)
+	assert.Error(t, err)
+}
+
+func TestMemoryStore_Timeout(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStoreWithInterval(10 * time.Millisecond)
+	defer s.Close()
+
+	assert.NoError(t, s.Set(ctx, "forever", "v", 0))
+	ttl, _ := s.GetTimeout(ctx, "forever")
+	assert.Equal(t, ttlNoExpire, ttl)
+
+	assert.NoError(t, s.Set(ctx, "short", "v", 20*time.Millisecond))
+	assert.NoError(t, s.UpdateTimeout(ctx, "forever", 20*time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+
+	val, _ := s.Get(ctx, "short")
+	assert.Equal(t, "", val)
+	val, _ = s.Get(ctx, "forever")
+	assert.Equal(t, "", val)
+
+	s.mu.RLock()
+	assert.Len(t, s.items, 0)
+	s.mu.RUnlock()
+}
+
+func TestMemoryStore_Obj(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	var sign satoken.TokenSign
+	assert.ErrorIs(t, s.GetObj(ctx, "obj", &sign), satoken.ErrObjectNotExist)
+
+	assert.NoError(t, s.SetObj(ctx, "obj", satoken.TokenSign{Value: "t", Device: "pc"}, time.Minute))
+	assert.NoError(t, s.GetObj(ctx, "obj", &sign))
+	assert.Equal(t, "t", sign.Value)
+	assert.Equal(t, "pc", sign.Device)
+
+	assert.NoError(t, s.UpdateObj(ctx, "obj", satoken.TokenSign{Value: "t", Device: "app"}))
+	assert.NoError(t, s.GetObj(ctx, "obj", &sign))
+	assert.Equal(t, "app", sign.Device)
+
+	assert.NoError(t, s.DeleteObj(ctx, "obj"))
+	assert.ErrorIs(t, s.GetObj(ctx, "obj", &sign), satoken.ErrObjectNotExist)
+
+	assert.NoError(t, s.SetObj(ctx, "obj", satoken.TokenSign{Value: "t"}, time.Minute))
+	assert.NoError(t, s.GetDelObj(ctx, "obj", &sign))
+	assert.Equal(t, "t", sign.Value)
+	assert.ErrorIs(t, s.GetDelObj(ctx, "obj", &sign), satoken.ErrObjectNotExist)
+}
+
+func TestMemoryStore_Scan(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	_ = s.Set(ctx, "a:1", "1", 0)
+	_ = s.Set(ctx, "a:2", "2", time.Minute)
+	_ = s.Set(ctx, "a:3", "3", time.Millisecond)
+	_ = s.Set(ctx, "b:1", "1", 0)
+	time.Sleep(5 * time.Millisecond)
+
+	keys := make([]string, 0)
+	assert.NoError(t, s.Scan(ctx, "a:", func(key string) bool {
+		keys = append(keys, key)
+		return true
+	}))
+	assert.ElementsMatch(t, []string{"a:1", "a:2"}, keys)
+
+	count := 0
+	assert.NoError(t, s.Scan(ctx, "", func(key string) bool {
+		count++
+		return false
+	}))
+	assert.Equal(t, 1, count)
+}
+
+func TestMemoryStore_UpdateObjWithVersion(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	type versioned struct {
+		Name    string `json:"name"`
+		Version int64  `json:"version"`
+	}
+	assert.NoError(t, s.UpdateObjWithVersion(ctx, "obj", versioned{Name: "a", Version: 1}, 0))
+	var ret versioned
+	assert.ErrorIs(t, s.GetObj(ctx, "obj", &ret), satoken.ErrObjectNotExist)
+
+	assert.NoError(t, s.SetObj(ctx, "obj", versioned{Name: "a"}, time.Minute))
+	assert.NoError(t, s.UpdateObjWithVersion(ctx, "obj", versioned{Name: "b", Version: 1}, 0))
+	assert.ErrorIs(t, s.UpdateObjWithVersion(ctx, "obj", versioned{Name: "c", Version: 1}, 0), satoken.ErrSessionConflict)
+	assert.NoError(t, s.GetObj(ctx, "obj", &ret))
+	assert.Equal(t, "b", ret.Name)
+	ttl, _ := s.GetObjTimeout(ctx, "obj")
+	assert.True(t, ttl > 59*time.Second)
+}
+
+func TestMemoryStore_CreateObj(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	var sign satoken.TokenSign
+	assert.NoError(t, s.CreateObj(ctx, "obj", satoken.TokenSign{Value: "a"}, time.Minute))
+	assert.ErrorIs(t, s.CreateObj(ctx, "obj", satoken.TokenSign{Value: "b"}, time.Minute), satoken.ErrSessionConflict)
+	assert.NoError(t, s.GetObj(ctx, "obj", &sign))
+	assert.Equal(t, "a", sign.Value)
+
+	// 批次提交前被其他请求创建
+	batch := s.Batch(ctx)
+	assert.NoError(t, batch.CreateObj(ctx, "batch", satoken.TokenSign{Value: "a"}, time.Minute))
+	assert.NoError(t, s.SetObj(ctx, "batch", satoken.TokenSign{Value: "b"}, time.Minute))
+	assert.ErrorIs(t, batch.Commit(ctx), satoken.ErrSessionConflict)
+	assert.NoError(t, s.GetObj(ctx, "batch", &sign))
+	assert.Equal(t, "b", sign.Value)
+
+	// 批次中先删除再创建
+	batch = s.Batch(ctx)
+	assert.NoError(t, batch.DeleteObj(ctx, "batch"))
+	assert.NoError(t, batch.CreateObj(ctx, "batch", satoken.TokenSign{Value: "c"}, time.Minute))
+	assert.NoError(t, batch.Commit(ctx))
+	assert.NoError(t, s.GetObj(ctx, "batch", &sign))
+	assert.Equal(t, "c", sign.Value)
+}
+
+func TestMemoryStore_Batch(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	type versioned struct {
+		Name    string `json:"name"`
+		Version int64  `json:"version"`
+	}
+	_ = s.Set(ctx, "mapping", "10001", time.Minute)
+	_ = s.SetObj(ctx, "session", versioned{Name: "a"}, time.Minute)
+
+	batch := s.Batch(ctx)
+	assert.NoError(t, batch.Delete(ctx, "mapping"))
+	assert.NoError(t, batch.UpdateObjWithVersion(ctx, "session", versioned{Name: "b", Version: 1}, 0))
+	// 提交前写入不可见
+	val, _ := s.Get(ctx, "mapping")
+	assert.Equal(t, "10001", val)
+	assert.NoError(t, batch.Commit(ctx))
+	val, _ = s.Get(ctx, "mapping")
+	assert.Equal(t, "", val)
+	var ret versioned
+	assert.NoError(t, s.GetObj(ctx, "session", &ret))
+	assert.Equal(t, "b", ret.Name)
+
+	// 版本冲突时不执行任何写入
+	batch = s.Batch(ctx)
+	assert.NoError(t, batch.Set(ctx, "mapping", "10002", time.Minute))
+	assert.NoError(t, batch.UpdateObjWithVersion(ctx, "session", versioned{Name: "c", Version: 1}, 0))
+	assert.ErrorIs(t, batch.Commit(ctx), satoken.ErrSessionConflict)
+	val, _ = s.Get(ctx, "mapping")
+	assert.Equal(t, "", val)
+	assert.NoError(t, s.GetObj(ctx, "session", &ret))
+	assert.Equal(t, "b", ret.Name)
+
+	// 期望的键值被修改时不执行任何写入
+	_ = s.Set(ctx, "family", "r1", time.Minute)
+	batch = s.Batch(ctx)
+	assert.NoError(t, batch.Expect(ctx, "family", "r1"))
+	assert.NoError(t, batch.Set(ctx, "family", "r2", time.Minute))
+	_ = s.Set(ctx, "family", "r3", time.Minute)
+	assert.ErrorIs(t, batch.Commit(ctx), satoken.ErrSessionConflict)
+	val, _ = s.Get(ctx, "family")
+	assert.Equal(t, "r3", val)
+
+	batch = s.Batch(ctx)
+	assert.NoError(t, batch.Expect(ctx, "family", "r3"))
+	assert.NoError(t, batch.Set(ctx, "family", "r4", time.Minute))
+	assert.NoError(t, batch.Commit(ctx))
+	val, _ = s.Get(ctx, "family")
+	assert.Equal(t, "r4", val)
+}
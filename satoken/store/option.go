@@ -0,0 +1,167 @@
+package store
+
+import (
+	"time"
+)
+
+// Option is the only struct that can be used to set Options.
+type Option struct {
+	F func(o *Options)
+}
+
+type Options struct {
+	// serializer 写入对象使用的序列化
+	serializer Serializer
+	// envelope 写入对象时是否记录序列化格式
+	envelope bool
+	// decoders 读取其他格式写入的对象，用于迁移序列化格式
+	decoders []Serializer
+	// cacheSize CacheStore 本地缓存的最大条目数
+	cacheSize int
+	// cacheTimeout CacheStore 本地缓存的有效期
+	cacheTimeout time.Duration
+	// cacheMissTimeout CacheStore 缓存不存在的键的有效期
+	cacheMissTimeout time.Duration
+	// cacheBypass CacheStore 不缓存的键
+	cacheBypass func(key string) bool
+	// bus CacheStore 跨实例失效通知
+	bus InvalidationBus
+	// fsyncPolicy FileStore 刷盘策略
+	fsyncPolicy FsyncPolicy
+	// compactInterval FileStore 检查是否需要压缩日志的间隔
+	compactInterval time.Duration
+}
+
+func (o *Options) Apply(opts []Option) {
+	for _, op := range opts {
+		op.F(o)
+	}
+}
+
+func NewOptions(opts ...Option) *Options {
+	options := &Options{
+		cacheSize:        10000,
+		cacheTimeout:     5 * time.Second,
+		cacheMissTimeout: time.Second,
+		fsyncPolicy:      FsyncEverySecond,
+		compactInterval:  time.Minute,
+	}
+	options.Apply(opts)
+	// 开启信封时默认保留数字精度，未开启时与之前直接写入的 json 一致
+	if options.serializer == nil {
+		if options.envelope {
+			options.serializer = jsonSerializer{}
+		} else {
+			options.serializer = defaultSerializer{}
+		}
+	}
+	return options
+}
+
+// WithSerializer set the serializer of the objects written by the store, default json with the package level
+// Marshal and Unmarshal decoding numbers as float64, or NewJsonSerializer with WithEnvelope.
+// Without WithEnvelope the stored objects can not be read after changing the serializer
+func WithSerializer(serializer Serializer) Option {
+	return Option{
+		F: func(o *Options) {
+			if serializer == nil {
+				panic("serializer cannot be nil")
+			}
+			o.serializer = serializer
+		},
+	}
+}
+
+// WithEnvelope write the objects in an envelope recording the serializer name, so the serializer can be changed
+// later with WithDecoders accepting the previous one. The objects stored without an envelope are read as json
+func WithEnvelope() Option {
+	return Option{
+		F: func(o *Options) {
+			o.envelope = true
+		},
+	}
+}
+
+// WithDecoders accept the objects written in an envelope by the serializers, so stored sessions survive
+// a format change, see WithEnvelope. Both json formats and the configured serializer are always accepted
+func WithDecoders(decoders ...Serializer) Option {
+	return Option{
+		F: func(o *Options) {
+			o.decoders = append(o.decoders, decoders...)
+		},
+	}
+}
+
+// WithCacheSize set the max entries of the CacheStore local cache, the least recently used are evicted
+func WithCacheSize(size int) Option {
+	return Option{
+		F: func(o *Options) {
+			if size <= 0 {
+				panic("cache size must be positive")
+			}
+			o.cacheSize = size
+		},
+	}
+}
+
+// WithCacheTimeout set how long the CacheStore keeps a local entry, it bounds the staleness
+// when an invalidation is lost or the key expires in the wrapped store
+func WithCacheTimeout(timeout time.Duration) Option {
+	return Option{
+		F: func(o *Options) {
+			if timeout <= 0 {
+				panic("cache timeout must be positive")
+			}
+			o.cacheTimeout = timeout
+		},
+	}
+}
+
+// WithCacheMissTimeout set how long the CacheStore remembers a missing key, so the lookups of invalid tokens
+// do not all reach the wrapped store, a non-positive timeout disables it. Default one second
+func WithCacheMissTimeout(timeout time.Duration) Option {
+	return Option{
+		F: func(o *Options) {
+			o.cacheMissTimeout = timeout
+		},
+	}
+}
+
+// WithCacheBypass set the keys the CacheStore reads and writes through the wrapped store without caching,
+// their writes neither invalidate nor broadcast, such as satoken.Manager.IsHotPathKey. Default all the keys are cached
+func WithCacheBypass(bypass func(key string) bool) Option {
+	return Option{
+		F: func(o *Options) {
+			o.cacheBypass = bypass
+		},
+	}
+}
+
+// WithInvalidationBus broadcast the changed keys of the CacheStore to the other instances,
+// without it only the local entries are invalidated
+func WithInvalidationBus(bus InvalidationBus) Option {
+	return Option{
+		F: func(o *Options) {
+			o.bus = bus
+		},
+	}
+}
+
+// WithFsyncPolicy set when the FileStore flushes the log to the disk, default FsyncEverySecond
+func WithFsyncPolicy(policy FsyncPolicy) Option {
+	return Option{
+		F: func(o *Options) {
+			o.fsyncPolicy = policy
+		},
+	}
+}
+
+// WithCompactInterval set how often the FileStore checks whether the log needs a compaction,
+// a non-positive interval disables the automatic compaction
+func WithCompactInterval(interval time.Duration) Option {
+	return Option{
+		F: func(o *Options) {
+			o.compactInterval = interval
+		},
+	}
+}
@@ -6,66 +6,85 @@ import (
 	"github.com/bytedance/sonic"
 	"github.com/myhaiting/go-fly-lib/satoken"
 	"github.com/redis/go-redis/v9"
+	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	_    satoken.TokenStore = &TokenStore{}
-	json                    = sonic.ConfigStd
-	// Marshal is exported by gin/json package.
+	_    satoken.TokenStore     = &TokenStore{}
+	_    satoken.Scanner        = &TokenStore{}
+	_    satoken.VersionedStore = &TokenStore{}
+	_    satoken.ObjTaker       = &TokenStore{}
+	_    satoken.Counter        = &TokenStore{}
+	json                        = sonic.ConfigStd
+	// Marshal is exported by gin/json package, used by the default serializer
 	Marshal = json.Marshal
-	// Unmarshal is exported by gin/json package.
+	// Unmarshal is exported by gin/json package, used by the default serializer
 	Unmarshal = json.Unmarshal
 )
 
+// scanCount SCAN 每次迭代的数量提示
+const scanCount = 1000
+
+// keepTTLArgs SET KEEPTTL XX，只更新已存在的键并保留过期时间，需要 Redis 6.0
+var keepTTLArgs = redis.SetArgs{KeepTTL: true, Mode: "XX"}
+
 // NewRedisStore create an instance of a redis store
-func NewRedisStore(opts *redis.Options) *TokenStore {
+func NewRedisStore(opts *redis.Options, options ...Option) *TokenStore {
 	if opts == nil {
 		panic("options cannot be nil")
 	}
-	return NewRedisStoreWithCli(redis.NewClient(opts))
+	return NewRedisStoreWithCli(redis.NewClient(opts), options...)
 }
 
 // NewRedisStoreWithCli create an instance of a redis store
-func NewRedisStoreWithCli(cli *redis.Client) *TokenStore {
-	return NewRedisStoreWithInterface(cli)
+func NewRedisStoreWithCli(cli *redis.Client, opts ...Option) *TokenStore {
+	return NewRedisStoreWithInterface(cli, opts...)
 }
 
 // NewRedisClusterStore create an instance of a redis cluster store
-func NewRedisClusterStore(opts *redis.ClusterOptions) *TokenStore {
+func NewRedisClusterStore(opts *redis.ClusterOptions, options ...Option) *TokenStore {
 	if opts == nil {
 		panic("options cannot be nil")
 	}
-	return NewRedisClusterStoreWithCli(redis.NewClusterClient(opts))
+	return NewRedisClusterStoreWithCli(redis.NewClusterClient(opts), options...)
 }
 
 // NewRedisClusterStoreWithCli create an instance of a redis cluster store
-func NewRedisClusterStoreWithCli(cli *redis.ClusterClient) *TokenStore {
-	return NewRedisStoreWithInterface(cli)
+func NewRedisClusterStoreWithCli(cli *redis.ClusterClient, opts ...Option) *TokenStore {
+	return NewRedisStoreWithInterface(cli, opts...)
 }
 
 // NewRedisStoreWithInterface create an instance of a redis store
-func NewRedisStoreWithInterface(cli clienter) *TokenStore {
+func NewRedisStoreWithInterface(cli clienter, opts ...Option) *TokenStore {
 	store := &TokenStore{
-		cli: cli,
+		cli:   cli,
+		codec: newCodec(NewOptions(opts...)),
 	}
 	return store
 }
 
 type clienter interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
+	GetDel(ctx context.Context, key string) *redis.StringCmd
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetArgs(ctx context.Context, key string, value interface{}, a redis.SetArgs) *redis.StatusCmd
 	TTL(ctx context.Context, key string) *redis.DurationCmd
 	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
 	Exists(ctx context.Context, key ...string) *redis.IntCmd
 	TxPipeline() redis.Pipeliner
+	Pipeline() redis.Pipeliner
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
 	Close() error
 }
 
 // TokenStore redis token store
 type TokenStore struct {
-	cli clienter
+	cli   clienter
+	codec *codec
 }
 
 func (s *TokenStore) checkError(result redis.Cmder) (bool, error) {
@@ -95,18 +114,31 @@ func (s *TokenStore) Set(ctx context.Context, key string, value string, exp time
 	return s.cli.Set(ctx, key, value, exp).Err()
 }
 
+// Update replace the value of an existing key keeping its ttl, a missing key is left unchanged
 func (s *TokenStore) Update(ctx context.Context, key string, val string) error {
-	ttl, err := s.cli.TTL(ctx, key).Result()
-	if err != nil {
-		return err
-	}
-	return s.cli.Set(ctx, key, val, ttl).Err()
+	_, err := s.checkError(s.cli.SetArgs(ctx, key, val, keepTTLArgs))
+	return err
 }
 
 func (s *TokenStore) Delete(ctx context.Context, key string) error {
 	return s.cli.Del(ctx, key).Err()
 }
 
+// Incr increase the integer value with INCR and EXPIRE in a MULTI transaction
+func (s *TokenStore) Incr(ctx context.Context, key string, exp time.Duration) (int64, error) {
+	pipe := s.cli.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	if exp > 0 {
+		pipe.Expire(ctx, key, exp)
+	} else {
+		pipe.Persist(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
 func (s *TokenStore) GetTimeout(ctx context.Context, key string) (time.Duration, error) {
 	return s.cli.TTL(ctx, key).Result()
 }
@@ -123,23 +155,78 @@ func (s *TokenStore) GetObj(ctx context.Context, key string, ret any) error {
 	if val == "" {
 		return satoken.ErrObjectNotExist
 	}
-	return Unmarshal([]byte(val), ret)
+	return s.codec.unmarshal(val, ret)
 }
 
 func (s *TokenStore) SetObj(ctx context.Context, key string, val any, exp time.Duration) error {
-	data, err := Marshal(val)
+	data, err := s.codec.marshal(val)
 	if err != nil {
 		return err
 	}
-	return s.Set(ctx, key, string(data), exp)
+	return s.Set(ctx, key, data, exp)
 }
 
 func (s *TokenStore) UpdateObj(ctx context.Context, key string, val any) error {
-	data, err := Marshal(val)
+	data, err := s.codec.marshal(val)
 	if err != nil {
 		return err
 	}
-	return s.Update(ctx, key, string(data))
+	return s.Update(ctx, key, data)
+}
+
+// UpdateObjWithVersion compare and update the object with WATCH and MULTI, the ttl is kept by KEEPTTL
+func (s *TokenStore) UpdateObjWithVersion(ctx context.Context, key string, val any, version int64) error {
+	data, err := s.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	err = s.cli.Watch(ctx, func(tx *redis.Tx) error {
+		stored, err := s.getValue(tx.Get(ctx, key))
+		if err != nil || stored == "" {
+			return err
+		}
+		if err = s.codec.checkVersion(stored, version); err != nil {
+			return err
+		}
+		return execPipeline(ctx, tx.TxPipeline(), []func(ctx context.Context, pipe redis.Pipeliner){
+			func(ctx context.Context, pipe redis.Pipeliner) {
+				pipe.SetArgs(ctx, key, data, keepTTLArgs)
+			},
+		})
+	}, key)
+	// 提交前键被修改
+	if errors.Is(err, redis.TxFailedErr) {
+		return satoken.ErrSessionConflict
+	}
+	return err
+}
+
+// CreateObj set the object with SET NX
+func (s *TokenStore) CreateObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	data, err := s.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	notSet, err := s.checkError(s.cli.SetArgs(ctx, key, data, redis.SetArgs{Mode: "NX", TTL: exp}))
+	if err != nil {
+		return err
+	}
+	if notSet {
+		return satoken.ErrSessionConflict
+	}
+	return nil
+}
+
+// GetDelObj read and delete the object with GETDEL, which requires Redis 6.2
+func (s *TokenStore) GetDelObj(ctx context.Context, key string, ret any) error {
+	val, err := s.getValue(s.cli.GetDel(ctx, key))
+	if err != nil {
+		return err
+	}
+	if val == "" {
+		return satoken.ErrObjectNotExist
+	}
+	return s.codec.unmarshal(val, ret)
 }
 
 func (s *TokenStore) DeleteObj(ctx context.Context, key string) error {
@@ -153,3 +240,61 @@ func (s *TokenStore) GetObjTimeout(ctx context.Context, key string) (time.Durati
 func (s *TokenStore) UpdateObjTimeout(ctx context.Context, key string, exp time.Duration) error {
 	return s.cli.Expire(ctx, key, exp).Err()
 }
+
+type keyScanner interface {
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+}
+
+// Scan iterate the keys with SCAN, a cluster scans every master node
+func (s *TokenStore) Scan(ctx context.Context, prefix string, fn func(key string) bool) error {
+	match := escapeScanPattern(prefix) + "*"
+	cluster, ok := s.cli.(*redis.ClusterClient)
+	if !ok {
+		return scanKeys(ctx, s.cli, match, fn)
+	}
+	// 各节点并发扫描，串行回调
+	var mu sync.Mutex
+	stopped := false
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		return scanKeys(ctx, client, match, func(key string) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if !stopped && !fn(key) {
+				stopped = true
+			}
+			return !stopped
+		})
+	})
+}
+
+func scanKeys(ctx context.Context, cli keyScanner, match string, fn func(key string) bool) error {
+	var cursor uint64
+	for {
+		keys, next, err := cli.Scan(ctx, cursor, match, scanCount).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !fn(key) {
+				return nil
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// 转义 SCAN 模式中的通配符
+func escapeScanPattern(prefix string) string {
+	var builder strings.Builder
+	for _, r := range prefix {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,250 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+var (
+	_ satoken.Batcher       = &TokenStore{}
+	_ satoken.AtomicBatcher = &TokenStore{}
+)
+
+// Batch start a batch committed by MULTI/EXEC, versioned objects and expected values are checked with WATCH.
+// With a cluster the batch is atomic only if all the keys are in one slot, wrap Config.TokenName in a hash tag
+// such as {satoken}, otherwise the values are checked before the writes are pipelined without a transaction
+func (s *TokenStore) Batch(_ context.Context) satoken.Batch {
+	return &redisBatch{
+		TokenStore: s,
+		versions:   make(map[string]int64),
+		written:    make(map[string]bool),
+		expects:    make(map[string]string),
+	}
+}
+
+// IsAtomicBatch the batches of a cluster may fall back to a non-transactional commit
+func (s *TokenStore) IsAtomicBatch() bool {
+	_, ok := s.cli.(*redis.ClusterClient)
+	return !ok
+}
+
+// redisBatch queue the writes, reads are served by the embedded store
+type redisBatch struct {
+	*TokenStore
+	ops []func(ctx context.Context, pipe redis.Pipeliner)
+	// 每个键只校验批次中首次写入时的版本
+	versions map[string]int64
+	// 批次中重新写入或删除的键不再校验版本
+	written map[string]bool
+	// 提交时要求的键值
+	expects map[string]string
+	// WATCH 的键
+	keys []string
+	// 写入的键
+	writtenKeys []string
+}
+
+func (b *redisBatch) queue(key string, op func(ctx context.Context, pipe redis.Pipeliner)) {
+	b.ops = append(b.ops, op)
+	b.writtenKeys = append(b.writtenKeys, key)
+}
+
+func (b *redisBatch) Set(_ context.Context, key string, value string, exp time.Duration) error {
+	b.written[key] = true
+	b.queue(key, func(ctx context.Context, pipe redis.Pipeliner) {
+		pipe.Set(ctx, key, value, exp)
+	})
+	return nil
+}
+
+func (b *redisBatch) Update(_ context.Context, key string, val string) error {
+	b.queue(key, func(ctx context.Context, pipe redis.Pipeliner) {
+		pipe.SetArgs(ctx, key, val, keepTTLArgs)
+	})
+	return nil
+}
+
+func (b *redisBatch) Delete(_ context.Context, key string) error {
+	b.written[key] = true
+	b.queue(key, func(ctx context.Context, pipe redis.Pipeliner) {
+		pipe.Del(ctx, key)
+	})
+	return nil
+}
+
+func (b *redisBatch) UpdateTimeout(_ context.Context, key string, exp time.Duration) error {
+	b.queue(key, func(ctx context.Context, pipe redis.Pipeliner) {
+		pipe.Expire(ctx, key, exp)
+	})
+	return nil
+}
+
+func (b *redisBatch) SetObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	data, err := b.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return b.Set(ctx, key, data, exp)
+}
+
+func (b *redisBatch) UpdateObj(ctx context.Context, key string, val any) error {
+	data, err := b.codec.marshal(val)
+	if err != nil {
+		return err
+	}
+	return b.Update(ctx, key, data)
+}
+
+func (b *redisBatch) DeleteObj(ctx context.Context, key string) error {
+	return b.Delete(ctx, key)
+}
+
+func (b *redisBatch) UpdateObjTimeout(ctx context.Context, key string, exp time.Duration) error {
+	return b.UpdateTimeout(ctx, key, exp)
+}
+
+func (b *redisBatch) UpdateObjWithVersion(ctx context.Context, key string, val any, version int64) error {
+	if _, ok := b.versions[key]; !ok && !b.written[key] {
+		b.versions[key] = version
+		b.watch(key)
+	}
+	return b.UpdateObj(ctx, key, val)
+}
+
+// CreateObj 批次中已写入或删除的键不再要求不存在
+func (b *redisBatch) CreateObj(ctx context.Context, key string, val any, exp time.Duration) error {
+	if !b.written[key] {
+		b.expects[key] = ""
+		b.watch(key)
+	}
+	return b.SetObj(ctx, key, val, exp)
+}
+
+func (b *redisBatch) Expect(_ context.Context, key string, value string) error {
+	b.expects[key] = value
+	b.watch(key)
+	return nil
+}
+
+func (b *redisBatch) watch(key string) {
+	for _, watched := range b.keys {
+		if watched == key {
+			return
+		}
+	}
+	b.keys = append(b.keys, key)
+}
+
+func (b *redisBatch) Commit(ctx context.Context) error {
+	if len(b.ops) == 0 {
+		return nil
+	}
+	// 集群中的键不在同一个槽时不能使用事务
+	if _, ok := b.cli.(*redis.ClusterClient); ok && !sameSlot(b.keys, b.writtenKeys) {
+		return b.commitWithoutTx(ctx)
+	}
+	if len(b.keys) == 0 {
+		return execPipeline(ctx, b.cli.TxPipeline(), b.ops)
+	}
+	err := b.cli.Watch(ctx, func(tx *redis.Tx) error {
+		for _, key := range b.keys {
+			stored, err := b.getValue(tx.Get(ctx, key))
+			if err != nil {
+				return err
+			}
+			if err = b.check(key, stored); err != nil {
+				return err
+			}
+		}
+		return execPipeline(ctx, tx.TxPipeline(), b.ops)
+	}, b.keys...)
+	// 提交前键被修改
+	if errors.Is(err, redis.TxFailedErr) {
+		return satoken.ErrSessionConflict
+	}
+	return err
+}
+
+// 逐个校验后以普通管道写入，校验与写入之间其他请求的修改无法发现
+func (b *redisBatch) commitWithoutTx(ctx context.Context) error {
+	for _, key := range b.keys {
+		stored, err := b.getValue(b.cli.Get(ctx, key))
+		if err != nil {
+			return err
+		}
+		if err = b.check(key, stored); err != nil {
+			return err
+		}
+	}
+	return execPipeline(ctx, b.cli.Pipeline(), b.ops)
+}
+
+// 校验键的期望值和版本
+func (b *redisBatch) check(key string, stored string) error {
+	if value, ok := b.expects[key]; ok && stored != value {
+		return satoken.ErrSessionConflict
+	}
+	if version, ok := b.versions[key]; ok && stored != "" {
+		return b.codec.checkVersion(stored, version)
+	}
+	return nil
+}
+
+// 所有键是否在同一个集群槽
+func sameSlot(keyLists ...[]string) bool {
+	first := -1
+	for _, keys := range keyLists {
+		for _, key := range keys {
+			slot := int(keySlot(key))
+			if first < 0 {
+				first = slot
+			} else if slot != first {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 与 Redis Cluster 相同的槽计算，键中非空的 {hash tag} 决定槽
+func keySlot(key string) uint16 {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	// CRC16-CCITT (XMODEM)
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc % 16384
+}
+
+// 执行事务，XX 条件未满足时的 redis.Nil 不是错误
+func execPipeline(ctx context.Context, pipe redis.Pipeliner, ops []func(ctx context.Context, pipe redis.Pipeliner)) error {
+	for _, op := range ops {
+		op(ctx, pipe)
+	}
+	cmds, err := pipe.Exec(ctx)
+	if errors.Is(err, redis.TxFailedErr) {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err = cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type versionedObj struct {
+	Name    string `json:"name"`
+	Version int64  `json:"version"`
+}
+
+func newTestRedisStore(t *testing.T) (*TokenStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	s := NewRedisStore(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = s.cli.Close()
+	})
+	return s, mr
+}
+
+func TestEscapeScanPattern(t *testing.T) {
+	assert.Equal(t, "satoken:login:session:", escapeScanPattern("satoken:login:session:"))
+	assert.Equal(t, `a\*b\?c\[d\]e\\`, escapeScanPattern(`a*b?c[d]e\`))
+}
+
+func TestKeySlot(t *testing.T) {
+	assert.Equal(t, uint16(12182), keySlot("foo"))
+	assert.Equal(t, uint16(0x31C3), keySlot("123456789"))
+	assert.Equal(t, keySlot("user1000"), keySlot("{user1000}.following"))
+	assert.Equal(t, keySlot("{}.following"), keySlot("{}.following"))
+	assert.True(t, sameSlot([]string{"{satoken}:a"}, []string{"{satoken}:b"}))
+	assert.False(t, sameSlot([]string{"satoken:a", "satoken:b"}))
+}
+
+func TestRedisStore_Update(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestRedisStore(t)
+
+	assert.NoError(t, s.Set(ctx, "k", "v", time.Minute))
+	assert.NoError(t, s.Update(ctx, "k", "v2"))
+	val, _ := s.Get(ctx, "k")
+	assert.Equal(t, "v2", val)
+	// SET KEEPTTL XX 保留过期时间
+	assert.Equal(t, time.Minute, mr.TTL("k"))
+
+	// 不存在的键不会被创建
+	assert.NoError(t, s.Update(ctx, "missing", "v"))
+	assert.False(t, mr.Exists("missing"))
+}
+
+func TestRedisStore_Obj(t *testing.T) {
+	ctx := context.Background()
+	s, mr := newTestRedisStore(t)
+
+	assert.NoError(t, s.UpdateObjWithVersion(ctx, "obj", versionedObj{Name: "a", Version: 1}, 0))
+	assert.False(t, mr.Exists("obj"))
+
+	assert.NoError(t, s.CreateObj(ctx, "obj", versionedObj{Name: "a"}, time.Minute))
+	assert.ErrorIs(t, s.CreateObj(ctx, "obj", versionedObj{Name: "x"}, time.Minute), satoken.ErrSessionConflict)
+	assert.NoError(t, s.UpdateObjWithVersion(ctx, "obj", versionedObj{Name: "b", Version: 1}, 0))
+	assert.ErrorIs(t, s.UpdateObjWithVersion(ctx, "obj", versionedObj{Name: "c", Version: 1}, 0), satoken.ErrSessionConflict)
+	assert.Equal(t, time.Minute, mr.TTL("obj"))
+
+	var ret versionedObj
+	assert.NoError(t, s.GetDelObj(ctx, "obj", &ret))
+	assert.Equal(t, "b", ret.Name)
+	assert.ErrorIs(t, s.GetDelObj(ctx, "obj", &ret), satoken.ErrObjectNotExist)
+
+	for i := int64(1); i <= 2; i++ {
+		value, err := s.Incr(ctx, "counter", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+	assert.Equal(t, time.Minute, mr.TTL("counter"))
+}
+
+func testRedisBatch(t *testing.T, s *TokenStore) {
+	ctx := context.Background()
+	_ = s.Set(ctx, "satoken:mapping", "10001", time.Minute)
+	_ = s.SetObj(ctx, "satoken:session", versionedObj{Name: "a"}, time.Minute)
+
+	batch := s.Batch(ctx)
+	assert.NoError(t, batch.Delete(ctx, "satoken:mapping"))
+	assert.NoError(t, batch.UpdateObjWithVersion(ctx, "satoken:session", versionedObj{Name: "b", Version: 1}, 0))
+	assert.NoError(t, batch.CreateObj(ctx, "satoken:created", versionedObj{Name: "a"}, time.Minute))
+	// 提交前写入不可见
+	val, _ := s.Get(ctx, "satoken:mapping")
+	assert.Equal(t, "10001", val)
+	assert.NoError(t, batch.Commit(ctx))
+	val, _ = s.Get(ctx, "satoken:mapping")
+	assert.Equal(t, "", val)
+	var ret versionedObj
+	assert.NoError(t, s.GetObj(ctx, "satoken:session", &ret))
+	assert.Equal(t, "b", ret.Name)
+	assert.NoError(t, s.GetObj(ctx, "satoken:created", &ret))
+
+	// 版本冲突时不执行任何写入
+	batch = s.Batch(ctx)
+	assert.NoError(t, batch.Set(ctx, "satoken:mapping", "10002", time.Minute))
+	assert.NoError(t, batch.UpdateObjWithVersion(ctx, "satoken:session", versionedObj{Name: "c", Version: 1}, 0))
+	assert.ErrorIs(t, batch.Commit(ctx), satoken.ErrSessionConflict)
+	val, _ = s.Get(ctx, "satoken:mapping")
+	assert.Equal(t, "", val)
+
+	// 期望值或不存在的要求未满足时不执行任何写入
+	_ = s.Set(ctx, "satoken:family", "r1", time.Minute)
+	batch = s.Batch(ctx)
+	assert.NoError(t, batch.Expect(ctx, "satoken:family", "r0"))
+	assert.NoError(t, batch.Set(ctx, "satoken:family", "r2", time.Minute))
+	assert.ErrorIs(t, batch.Commit(ctx), satoken.ErrSessionConflict)
+	batch = s.Batch(ctx)
+	assert.NoError(t, batch.CreateObj(ctx, "satoken:created", versionedObj{Name: "x"}, time.Minute))
+	assert.NoError(t, batch.Set(ctx, "satoken:family", "r2", time.Minute))
+	assert.ErrorIs(t, batch.Commit(ctx), satoken.ErrSessionConflict)
+	val, _ = s.Get(ctx, "satoken:family")
+	assert.Equal(t, "r1", val)
+}
+
+func TestRedisStore_Batch(t *testing.T) {
+	s, _ := newTestRedisStore(t)
+	assert.True(t, s.IsAtomicBatch())
+	testRedisBatch(t, s)
+}
+
+func TestRedisStore_ClusterBatch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := NewRedisClusterStore(&redis.ClusterOptions{Addrs: []string{mr.Addr()}})
+	t.Cleanup(func() {
+		_ = s.cli.Close()
+	})
+	// 键不在同一个槽时不使用事务
+	assert.False(t, sameSlot([]string{"satoken:mapping", "satoken:session"}))
+	assert.False(t, s.IsAtomicBatch())
+	testRedisBatch(t, s)
+}
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"bytes"
+	"encoding/gob"
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bytedance/sonic"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/vmihailenco/msgpack/v5"
+	"sync"
+)
+
+const (
+	// 信封以 0 字节开头，旧版本直接写入的 json 不会以 0 开头
+	envelopeMagic byte = 0
+	// 信封格式版本
+	envelopeVersion byte = 1
+)
+
+var (
+	errInvalidEnvelope = errors.New("store: invalid serializer envelope")
+
+	jsonNumberApi = sonic.Config{
+		EscapeHTML:       true,
+		SortMapKeys:      true,
+		CompactMarshaler: true,
+		CopyString:       true,
+		ValidateString:   true,
+		UseNumber:        true,
+	}.Froze()
+	gobRegisterOnce sync.Once
+)
+
+// Serializer encode and decode the objects of the store
+type Serializer interface {
+	// Name the format name recorded with each object, at most 255 bytes
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// 默认序列化，使用包级别的 Marshal 和 Unmarshal，数字解码为 float64
+type defaultSerializer struct{}
+
+func (defaultSerializer) Name() string {
+	return "json"
+}
+
+func (defaultSerializer) Marshal(v any) ([]byte, error) {
+	return Marshal(v)
+}
+
+func (defaultSerializer) Unmarshal(data []byte, v any) error {
+	return Unmarshal(data, v)
+}
+
+// NewJsonSerializer json serializer named "json-number", numbers decoded into any are kept as json.Number,
+// unlike the default serializer decoding them as float64. It is recommended for int64 login ids above 2^53,
+// and is the default serializer with WithEnvelope
+func NewJsonSerializer() Serializer {
+	return jsonSerializer{}
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) Name() string {
+	return "json-number"
+}
+
+func (jsonSerializer) Marshal(v any) ([]byte, error) {
+	return jsonNumberApi.Marshal(v)
+}
+
+func (jsonSerializer) Unmarshal(data []byte, v any) error {
+	return jsonNumberApi.Unmarshal(data, v)
+}
+
+// NewMsgpackSerializer MessagePack serializer, the json tags are used as field names
+func NewMsgpackSerializer() Serializer {
+	return msgpackSerializer{}
+}
+
+type msgpackSerializer struct{}
+
+func (msgpackSerializer) Name() string {
+	return "msgpack"
+}
+
+func (msgpackSerializer) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackSerializer) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	// 整数解码为 int64 或 uint64
+	dec.UseLooseInterfaceDecoding(true)
+	return dec.Decode(v)
+}
+
+// NewGobSerializer gob serializer, the concrete types stored in any fields such as Session.Data
+// must be registered by gob.Register, json.Number and the generic map and slice are registered
+func NewGobSerializer() Serializer {
+	gobRegisterOnce.Do(func() {
+		// json 解码的旧数据迁移到 gob 时使用的类型
+		gob.Register(stdjson.Number(""))
+		gob.Register(map[string]any{})
+		gob.Register([]any{})
+	})
+	return gobSerializer{}
+}
+
+type gobSerializer struct{}
+
+func (gobSerializer) Name() string {
+	return "gob"
+}
+
+func (gobSerializer) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobSerializer) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// codec 开启信封时记录序列化格式：0 字节、信封版本、格式名长度、格式名、数据，
+// 未开启时直接写入数据。信封总是可以读取，开启信封时没有信封的旧数据按 json 解码，
+// 两种 json 格式总是可以读取
+type codec struct {
+	serializer Serializer
+	decoders   map[string]Serializer
+	legacy     Serializer
+	envelope   bool
+}
+
+func newCodec(opts *Options) *codec {
+	if len(opts.serializer.Name()) > 255 {
+		panic("serializer name is too long")
+	}
+	c := &codec{
+		serializer: opts.serializer,
+		decoders:   make(map[string]Serializer),
+		legacy:     defaultSerializer{},
+		envelope:   opts.envelope,
+	}
+	for _, decoder := range append([]Serializer{c.legacy, jsonSerializer{}}, opts.decoders...) {
+		c.decoders[decoder.Name()] = decoder
+	}
+	c.decoders[c.serializer.Name()] = c.serializer
+	return c
+}
+
+func (c *codec) marshal(v any) (string, error) {
+	data, err := c.serializer.Marshal(v)
+	if err != nil || !c.envelope {
+		return string(data), err
+	}
+	name := c.serializer.Name()
+	buf := make([]byte, 0, 3+len(name)+len(data))
+	buf = append(buf, envelopeMagic, envelopeVersion, byte(len(name)))
+	buf = append(buf, name...)
+	return string(append(buf, data...)), nil
+}
+
+func (c *codec) unmarshal(stored string, v any) error {
+	data := []byte(stored)
+	if len(data) == 0 || data[0] != envelopeMagic {
+		if !c.envelope {
+			return c.serializer.Unmarshal(data, v)
+		}
+		return c.legacy.Unmarshal(data, v)
+	}
+	if len(data) < 3 || len(data) < 3+int(data[2]) {
+		return errInvalidEnvelope
+	}
+	if data[1] != envelopeVersion {
+		return fmt.Errorf("store: unsupported serializer envelope version %d", data[1])
+	}
+	name := string(data[3 : 3+int(data[2])])
+	decoder, ok := c.decoders[name]
+	if !ok {
+		return fmt.Errorf("store: no decoder of the serializer %q", name)
+	}
+	return decoder.Unmarshal(data[3+int(data[2]):], v)
+}
+
+// 版本化对象的版本字段
+type versionHeader struct {
+	Version int64 `json:"version"`
+}
+
+// 校验存储对象的版本
+func (c *codec) checkVersion(stored string, version int64) error {
+	var header versionHeader
+	if err := c.unmarshal(stored, &header); err != nil {
+		return err
+	}
+	if header.Version != version {
+		return satoken.ErrSessionConflict
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSerializer_LargeLoginId(t *testing.T) {
+	ctx := context.Background()
+	for _, serializer := range []Serializer{NewJsonSerializer(), NewMsgpackSerializer(), NewGobSerializer()} {
+		t.Run(serializer.Name(), func(t *testing.T) {
+			s := NewMemoryStore(WithSerializer(serializer))
+			defer s.Close()
+
+			sess := &satoken.Session{
+				Id:            "session",
+				LoginId:       int64(9007199254740993),
+				Data:          map[string]any{"name": "demo"},
+				TokenSignList: []*satoken.TokenSign{{Value: "token", Device: "pc"}},
+			}
+			assert.NoError(t, s.SetObj(ctx, "session", sess, time.Minute))
+			var ret satoken.Session
+			assert.NoError(t, s.GetObj(ctx, "session", &ret))
+			assert.Equal(t, "9007199254740993", cast.ToString(ret.LoginId))
+			assert.Equal(t, "demo", ret.Data["name"])
+			assert.Equal(t, "pc", ret.TokenSignList[0].Device)
+
+			ret.Version = 1
+			assert.NoError(t, s.UpdateObjWithVersion(ctx, "session", &ret, 0))
+			assert.ErrorIs(t, s.UpdateObjWithVersion(ctx, "session", &ret, 0), satoken.ErrSessionConflict)
+		})
+	}
+}
+
+func TestSerializer_Default(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	defer s.Close()
+
+	// 默认直接写入 json，数字解码为 float64
+	assert.NoError(t, s.SetObj(ctx, "session", satoken.Session{Id: "session", LoginId: 10001}, time.Minute))
+	stored, _ := s.Get(ctx, "session")
+	assert.Equal(t, byte('{'), stored[0])
+	var ret satoken.Session
+	assert.NoError(t, s.GetObj(ctx, "session", &ret))
+	assert.Equal(t, float64(10001), ret.LoginId)
+
+	// 读取时仍接受信封
+	data, err := newCodec(NewOptions(WithEnvelope())).marshal(satoken.Session{Id: "envelope"})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set(ctx, "envelope", data, time.Minute))
+	assert.NoError(t, s.GetObj(ctx, "envelope", &ret))
+	assert.Equal(t, "envelope", ret.Id)
+}
+
+func TestSerializer_EnvelopeDefault(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(WithEnvelope())
+	defer s.Close()
+
+	// 开启信封时默认保留数字精度
+	assert.NoError(t, s.SetObj(ctx, "session", satoken.Session{Id: "session", LoginId: int64(9007199254740993)}, time.Minute))
+	stored, _ := s.Get(ctx, "session")
+	assert.Equal(t, "json-number", stored[3:3+int(stored[2])])
+	var ret satoken.Session
+	assert.NoError(t, s.GetObj(ctx, "session", &ret))
+	assert.Equal(t, "9007199254740993", cast.ToString(ret.LoginId))
+
+	// 未开启信封的存储也能读取
+	plain := NewMemoryStore()
+	defer plain.Close()
+	assert.NoError(t, plain.Set(ctx, "session", stored, time.Minute))
+	assert.NoError(t, plain.GetObj(ctx, "session", &ret))
+	assert.Equal(t, "9007199254740993", cast.ToString(ret.LoginId))
+}
+
+func TestSerializer_Migrate(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(WithSerializer(NewMsgpackSerializer()), WithEnvelope(), WithDecoders(NewGobSerializer()))
+	defer s.Close()
+
+	// 旧版本直接写入的 json
+	assert.NoError(t, s.Set(ctx, "legacy", `{"id":"legacy","loginId":10001}`, time.Minute))
+	var ret satoken.Session
+	assert.NoError(t, s.GetObj(ctx, "legacy", &ret))
+	assert.Equal(t, "10001", cast.ToString(ret.LoginId))
+
+	// 其他格式写入的对象
+	data, err := newCodec(NewOptions(WithSerializer(NewGobSerializer()), WithEnvelope())).marshal(satoken.Session{Id: "gob", LoginId: "10002"})
+	assert.NoError(t, err)
+	assert.NoError(t, s.Set(ctx, "gob", data, time.Minute))
+	assert.NoError(t, s.GetObj(ctx, "gob", &ret))
+	assert.Equal(t, "10002", ret.LoginId)
+
+	// 重新保存后使用新格式
+	assert.NoError(t, s.UpdateObj(ctx, "gob", &ret))
+	stored, _ := s.Get(ctx, "gob")
+	assert.Equal(t, "msgpack", stored[3:3+int(stored[2])])
+
+	// 未接受的格式
+	jsonStore := NewMemoryStore()
+	defer jsonStore.Close()
+	assert.NoError(t, jsonStore.Set(ctx, "gob", data, time.Minute))
+	assert.Error(t, jsonStore.GetObj(ctx, "gob", &ret))
+}
@@ -0,0 +1,75 @@
+package satoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/myhaiting/go-fly-lib/bizerr"
+	"time"
+)
+
+// CreateTemp create a temporary token bound to the value, such as an email verification link,
+// ttl NeverExpire keeps it until deleted
+func (m *Manager) CreateTemp(ctx context.Context, value any, ttl time.Duration) (string, error) {
+	if ttl == 0 {
+		return "", fmt.Errorf("temp token ttl must not be zero")
+	}
+	tokenValue, err := randomString(64)
+	if err != nil {
+		return "", err
+	}
+	if err = m.getStore(ctx).SetObj(ctx, m.splicingKeyTempToken(ctx, tokenValue), value, storeTimeout(ttl)); err != nil {
+		return "", err
+	}
+	return tokenValue, nil
+}
+
+// ParseTemp decode the value bound to the temporary token into v, the token stays valid
+func (m *Manager) ParseTemp(ctx context.Context, tokenValue string, v any) error {
+	if tokenValue == "" {
+		return bizerr.WrapBizError(ctx, ErrTempTokenInvalid)
+	}
+	if err := m.getStore(ctx).GetObj(ctx, m.splicingKeyTempToken(ctx, tokenValue), v); err != nil {
+		if errors.Is(err, ErrObjectNotExist) {
+			return bizerr.WrapBizError(ctx, ErrTempTokenInvalid)
+		}
+		return err
+	}
+	return nil
+}
+
+// ConsumeTemp decode the value bound to the temporary token into v and delete the token,
+// a token can be consumed only once. The store should implement ObjTaker,
+// otherwise a token is only guaranteed to be consumed once per Manager instance
+func (m *Manager) ConsumeTemp(ctx context.Context, tokenValue string, v any) error {
+	if tokenValue == "" {
+		return bizerr.WrapBizError(ctx, ErrTempTokenInvalid)
+	}
+	// 存储支持时原子读取并删除，多个实例并发消费也只有一个成功
+	if taker, ok := m.getStore(ctx).(ObjTaker); ok {
+		if err := taker.GetDelObj(ctx, m.splicingKeyTempToken(ctx, tokenValue), v); err != nil {
+			if errors.Is(err, ErrObjectNotExist) {
+				return bizerr.WrapBizError(ctx, ErrTempTokenInvalid)
+			}
+			return err
+		}
+		return nil
+	}
+	// 否则只能在同一实例内串行消费
+	m.tempMu.Lock()
+	defer m.tempMu.Unlock()
+	if err := m.ParseTemp(ctx, tokenValue, v); err != nil {
+		return err
+	}
+	return m.DeleteTemp(ctx, tokenValue)
+}
+
+// DeleteTemp delete the temporary token
+func (m *Manager) DeleteTemp(ctx context.Context, tokenValue string) error {
+	return m.getStore(ctx).DeleteObj(ctx, m.splicingKeyTempToken(ctx, tokenValue))
+}
+
+// GetTempTimeout get the remaining time of the temporary token, NeverExpire if it never expires
+func (m *Manager) GetTempTimeout(ctx context.Context, tokenValue string) (time.Duration, error) {
+	return m.getStore(ctx).GetObjTimeout(ctx, m.splicingKeyTempToken(ctx, tokenValue))
+}
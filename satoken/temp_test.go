@@ -0,0 +1,56 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManager_Temp(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+
+	type resetLink struct {
+		UserId int64  `json:"userId"`
+		Email  string `json:"email"`
+	}
+	token, err := mgr.CreateTemp(ctx, resetLink{UserId: 10001, Email: "demo@example.com"}, time.Minute)
+	assert.NoError(t, err)
+
+	var link resetLink
+	assert.NoError(t, mgr.ParseTemp(ctx, token, &link))
+	assert.Equal(t, int64(10001), link.UserId)
+	ttl, _ := mgr.GetTempTimeout(ctx, token)
+	assert.True(t, ttl > 59*time.Second)
+
+	// 只能消费一次
+	link = resetLink{}
+	assert.NoError(t, mgr.ConsumeTemp(ctx, token, &link))
+	assert.Equal(t, "demo@example.com", link.Email)
+	assert.ErrorIs(t, mgr.ConsumeTemp(ctx, token, &link), satoken.ErrTempTokenInvalid)
+
+	expired, _ := mgr.CreateTemp(ctx, "download:42", 20*time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	var value string
+	assert.ErrorIs(t, mgr.ParseTemp(ctx, expired, &value), satoken.ErrTempTokenInvalid)
+
+	deleted, _ := mgr.CreateTemp(ctx, "download:42", time.Minute)
+	assert.NoError(t, mgr.DeleteTemp(ctx, deleted))
+	assert.ErrorIs(t, mgr.ParseTemp(ctx, deleted, &value), satoken.ErrTempTokenInvalid)
+}
+
+func TestManager_ConsumeTempConcurrent(t *testing.T) {
+	ctx := context.Background()
+	// 两个实例共享存储
+	mgrs := newTestManagers(t, nil)
+	token, err := mgrs[0].CreateTemp(ctx, "download:42", time.Minute)
+	assert.NoError(t, err)
+
+	consumed := runConcurrent(mgrs, 20, func(mgr *satoken.Manager) error {
+		var value string
+		return mgr.ConsumeTemp(ctx, token, &value)
+	})
+	assert.Equal(t, 1, consumed)
+}
@@ -0,0 +1,76 @@
+package satoken
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"github.com/google/uuid"
+	"math/big"
+	"strings"
+)
+
+const (
+	// TokenStyleUUID 32 位不带横线的 uuid，默认风格
+	TokenStyleUUID = "uuid"
+	// TokenStyleSimpleUUID 同 TokenStyleUUID
+	TokenStyleSimpleUUID = "simple-uuid"
+	// TokenStyleDashedUUID 36 位带横线的 uuid
+	TokenStyleDashedUUID = "dashed-uuid"
+	TokenStyleRandom32   = "random-32"
+	TokenStyleRandom64   = "random-64"
+	TokenStyleRandom128  = "random-128"
+	TokenStyleTik        = "tik"
+)
+
+const tokenChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// TokenGenerator custom token value generator
+type TokenGenerator func(ctx context.Context, loginId any, model LoginModel) (string, error)
+
+// 生成Token值
+func (m *Manager) createTokenValue(ctx context.Context, loginId any, model LoginModel) (string, error) {
+	if m.jwtAlg != nil {
+		return m.createJwtValue(loginId, model)
+	}
+	if m.tokenGenerator != nil {
+		return m.tokenGenerator(ctx, loginId, model)
+	}
+	switch style := m.getConfigOrGlobal().TokenStyle; style {
+	case TokenStyleUUID, TokenStyleSimpleUUID, "":
+		return strings.ReplaceAll(uuid.New().String(), "-", ""), nil
+	case TokenStyleDashedUUID:
+		return uuid.New().String(), nil
+	case TokenStyleRandom32:
+		return randomString(32)
+	case TokenStyleRandom64:
+		return randomString(64)
+	case TokenStyleRandom128:
+		return randomString(128)
+	case TokenStyleTik:
+		prefix, err := randomString(2)
+		if err != nil {
+			return "", err
+		}
+		body, err := randomString(14)
+		if err != nil {
+			return "", err
+		}
+		return prefix + "_" + body + "__", nil
+	default:
+		return "", fmt.Errorf("unknown token style: %s", style)
+	}
+}
+
+// 使用安全随机数生成指定长度的字符串
+func randomString(length int) (string, error) {
+	charCount := big.NewInt(int64(len(tokenChars)))
+	buf := make([]byte, length)
+	for i := range buf {
+		n, err := rand.Int(rand.Reader, charCount)
+		if err != nil {
+			return "", err
+		}
+		buf[i] = tokenChars[n.Int64()]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,51 @@
+package satoken_test
+
+import (
+	"context"
+	"github.com/myhaiting/go-fly-lib/satoken"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func TestManager_TokenStyle(t *testing.T) {
+	ctx := context.Background()
+	styles := map[string]*regexp.Regexp{
+		"":                           regexp.MustCompile(`^[0-9a-f]{32}$`),
+		satoken.TokenStyleUUID:       regexp.MustCompile(`^[0-9a-f]{32}$`),
+		satoken.TokenStyleSimpleUUID: regexp.MustCompile(`^[0-9a-f]{32}$`),
+		satoken.TokenStyleDashedUUID: regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`),
+		satoken.TokenStyleRandom32:   regexp.MustCompile(`^[0-9a-zA-Z]{32}$`),
+		satoken.TokenStyleRandom64:   regexp.MustCompile(`^[0-9a-zA-Z]{64}$`),
+		satoken.TokenStyleRandom128:  regexp.MustCompile(`^[0-9a-zA-Z]{128}$`),
+		satoken.TokenStyleTik:        regexp.MustCompile(`^[0-9a-zA-Z]{2}_[0-9a-zA-Z]{14}__$`),
+	}
+	for style, pattern := range styles {
+		cfg := satoken.NewDefaultConfig()
+		cfg.TokenStyle = style
+		mgr := newTestManager(t, cfg)
+		token, err := mgr.Login(ctx, 10001, satoken.LoginModel{})
+		assert.NoError(t, err)
+		assert.Regexp(t, pattern, token, style)
+	}
+
+	cfg := satoken.NewDefaultConfig()
+	cfg.TokenStyle = "unknown"
+	mgr := newTestManager(t, cfg)
+	_, err := mgr.Login(ctx, 10001, satoken.LoginModel{})
+	assert.Error(t, err)
+}
+
+func TestManager_SetTokenGenerator(t *testing.T) {
+	ctx := context.Background()
+	mgr := newTestManager(t, nil)
+	mgr.SetTokenGenerator(func(ctx context.Context, loginId any, model satoken.LoginModel) (string, error) {
+		return model.Device + "-token", nil
+	})
+	token, err := mgr.Login(ctx, 10001, satoken.LoginModel{Device: "pc"})
+	assert.NoError(t, err)
+	assert.Equal(t, "pc-token", token)
+	loginId, err := mgr.GetLoginId(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "10001", loginId)
+}